@@ -8,6 +8,9 @@ import (
 	"github.com/microsoftgraph/msgraph-sdk-go-core/authentication"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/microsoft"
+	"io"
+	"io/ioutil"
+	"net/http"
 )
 
 const (
@@ -68,6 +71,46 @@ func microsoftGraphClient(ctx context.Context, token Token) (*msgraphsdk.GraphSe
 	return msgraphsdk.NewGraphServiceClient(adapter), nil
 }
 
+// graphDo 使用 token 发送 graph api 请求并返回原始响应，调用方负责关闭 Body
+func graphDo(ctx context.Context, token Token, method string, url string, payload io.Reader, extraHeader map[string][]string) (*http.Response, error) {
+	headers, err := token.HttpHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for k, vals := range extraHeader {
+		headers[k] = vals
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, payload)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header = headers
+	client, err := token.HttpClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error creating http client: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %v", err)
+	}
+	return resp, nil
+}
+
+// graphRequest 使用 token 发送 graph api 请求并读取响应内容
+func graphRequest(ctx context.Context, token Token, method string, url string, payload io.Reader, extraHeader map[string][]string) ([]byte, error) {
+	resp, err := graphDo(ctx, token, method, url, payload, extraHeader)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %v", err)
+	}
+	return body, nil
+}
+
 func (c *MicrosoftGraph) AuthUrl(ctx context.Context, scopes ...string) (string, error) {
 	u, err := c.app.AuthCodeURL(ctx, c.conf.ClientId, c.conf.RedirectURL, scopes)
 	if err != nil {
@@ -0,0 +1,473 @@
+package msclient
+
+import (
+	"context"
+	"fmt"
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	graphusers "github.com/microsoftgraph/msgraph-sdk-go/users"
+	"net/url"
+	"time"
+)
+
+const DefaultEventPageSize = 50
+
+// graphDateTimeLayout dateTimeTimeZone 中 dateTime 的格式，不带时区
+const graphDateTimeLayout = "2006-01-02T15:04:05.9999999"
+
+// MyCalendar 登录用户的默认日历，需要委托权限
+func (c *MicrosoftGraph) MyCalendar(token Token) Calendar {
+	return &myCalendar{token: token}
+}
+
+// UserCalendar 指定用户或会议室的默认日历，userId 为用户 id 或 userPrincipalName
+func (c *MicrosoftGraph) UserCalendar(token Token, userId string) Calendar {
+	return &myCalendar{token: token, userId: userId}
+}
+
+type Calendar interface {
+	Events(ctx context.Context, opts EventOptions) *Pager[Event]
+	CalendarView(ctx context.Context, start time.Time, end time.Time, opts EventOptions) *Pager[Event]
+	CreateEvent(ctx context.Context, event EventInput) (*Event, error)
+	Update(ctx context.Context, eventId string, event EventInput) (*Event, error)
+	Cancel(ctx context.Context, eventId string, comment string) error
+	Accept(ctx context.Context, eventId string, comment string, sendResponse bool) error
+	TentativelyAccept(ctx context.Context, eventId string, comment string, sendResponse bool) error
+	Decline(ctx context.Context, eventId string, comment string, sendResponse bool) error
+	GetSchedule(ctx context.Context, emails []string, window TimeWindow, interval time.Duration) ([]Schedule, error)
+	FindMeetingTimes(ctx context.Context, attendees []string, constraints MeetingConstraints) ([]MeetingTimeSuggestion, error)
+}
+
+type EventOptions struct {
+	// PageSize 每页条数，默认 DefaultEventPageSize
+	PageSize int32
+	// Max 最多返回条数，<= 0 时读取全部
+	Max int
+	// Filter odata $filter，如 categories/any(c:c eq 'Room')
+	Filter string
+}
+
+// EventAttendee 参会人，Type 为 required、optional 或 resource（会议室、设备），Response 为参会人的答复
+type EventAttendee struct {
+	MailAddress
+	Type     string `json:"type"`
+	Response string `json:"response,omitempty"`
+}
+
+// Event 日历中的事件，时间均为 UTC，全天事件为当天 0 点
+type Event struct {
+	ID               string          `json:"id"`
+	Subject          string          `json:"subject"`
+	BodyText         string          `json:"bodyText,omitempty"`
+	BodyHTML         string          `json:"bodyHtml,omitempty"`
+	Start            time.Time       `json:"start"`
+	End              time.Time       `json:"end"`
+	IsAllDay         bool            `json:"isAllDay"`
+	Location         string          `json:"location,omitempty"`
+	Organizer        MailAddress     `json:"organizer"`
+	Attendees        []EventAttendee `json:"attendees,omitempty"`
+	IsOnlineMeeting  bool            `json:"isOnlineMeeting"`
+	OnlineMeetingURL string          `json:"onlineMeetingUrl,omitempty"`
+	IsCancelled      bool            `json:"isCancelled"`
+	// Type singleInstance、occurrence、exception 或 seriesMaster，重复事件的实例 SeriesMasterID 为所属系列
+	Type           string `json:"type"`
+	SeriesMasterID string `json:"seriesMasterId,omitempty"`
+	ShowAs         string `json:"showAs,omitempty"`
+	WebLink        string `json:"webLink,omitempty"`
+
+	// Raw sdk 原始对象，用于读取 Event 中没有的字段
+	Raw models.Eventable `json:"-"`
+}
+
+// EventInput 创建或修改的事件，修改时只更新非零值字段
+type EventInput struct {
+	Subject string
+	Body    string
+	// HTML 为 true 时 Body 按 HTML 发送，否则为纯文本
+	HTML  bool
+	Start time.Time
+	End   time.Time
+	// TimeZone IANA 时区，如 Asia/Shanghai，为空时使用 UTC；全天事件的日期按该时区计算
+	TimeZone string
+	AllDay   bool
+	Location string
+	// Attendees 必需参会人，OptionalAttendees 可选参会人，Resources 会议室等资源的邮箱地址
+	Attendees         []string
+	OptionalAttendees []string
+	Resources         []string
+	// OnlineMeeting 为 true 时创建 Teams 会议
+	OnlineMeeting bool
+}
+
+type myCalendar struct {
+	token Token
+	// userId 为空时为登录用户 /me
+	userId string
+}
+
+func (c *myCalendar) user(client *msgraphsdk.GraphServiceClient) *graphusers.UserItemRequestBuilder {
+	if c.userId != "" {
+		return client.Users().ByUserId(c.userId)
+	}
+	return client.Me()
+}
+
+func (c *myCalendar) userPath() string {
+	if c.userId != "" {
+		return "/users/" + url.PathEscape(c.userId)
+	}
+	return "/me"
+}
+
+/*
+Events 分页读取日历中的事件，重复事件只返回系列本身，不展开
+https://learn.microsoft.com/en-us/graph/api/user-list-events?view=graph-rest-1.0
+*/
+func (c *myCalendar) Events(ctx context.Context, opts EventOptions) *Pager[Event] {
+	return c.events(fmt.Sprintf("%s/v1.0%s/events", GraphAPIHost, c.userPath()), nil, opts)
+}
+
+/*
+CalendarView 读取 [start, end) 内的事件，重复事件展开为各次实例
+https://learn.microsoft.com/en-us/graph/api/user-list-calendarview?view=graph-rest-1.0
+*/
+func (c *myCalendar) CalendarView(ctx context.Context, start time.Time, end time.Time, opts EventOptions) *Pager[Event] {
+	return c.events(fmt.Sprintf("%s/v1.0%s/calendarView", GraphAPIHost, c.userPath()), [][2]string{
+		{"startDateTime", start.UTC().Format(time.RFC3339)},
+		{"endDateTime", end.UTC().Format(time.RFC3339)},
+	}, opts)
+}
+
+func (c *myCalendar) events(u string, params [][2]string, opts EventOptions) *Pager[Event] {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultEventPageSize
+	}
+	if opts.Max > 0 && opts.Max < int(opts.PageSize) {
+		opts.PageSize = int32(opts.Max)
+	}
+	params = append(params, [2]string{"$top", fmt.Sprintf("%d", opts.PageSize)})
+	if opts.Filter != "" {
+		params = append(params, [2]string{"$filter", opts.Filter})
+	}
+	nextLink := u + "?" + odataQuery(params)
+
+	return newPager(opts.Max, func(ctx context.Context) ([]Event, bool, error) {
+		client, err := microsoftGraphClient(ctx, c.token)
+		if err != nil {
+			return nil, false, fmt.Errorf("token to ms graph client failed:%v", err)
+		}
+		resp, err := c.user(client).Events().WithUrl(nextLink).Get(ctx, nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("list events failed: %v", err)
+		}
+		page := make([]Event, 0, len(resp.GetValue()))
+		for _, item := range resp.GetValue() {
+			page = append(page, NewEvent(item))
+		}
+		nextLink = ""
+		if next := resp.GetOdataNextLink(); next != nil {
+			nextLink = *next
+		}
+		return page, nextLink != "", nil
+	})
+}
+
+/*
+CreateEvent 在日历中创建事件，有参会人时会发送会议邀请
+https://learn.microsoft.com/en-us/graph/api/user-post-events?view=graph-rest-1.0
+*/
+func (c *myCalendar) CreateEvent(ctx context.Context, event EventInput) (*Event, error) {
+	if event.Start.IsZero() || event.End.IsZero() {
+		return nil, fmt.Errorf("missing event start or end")
+	}
+	body, err := event.eventable()
+	if err != nil {
+		return nil, err
+	}
+	client, err := microsoftGraphClient(ctx, c.token)
+	if err != nil {
+		return nil, fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	created, err := c.user(client).Events().Post(ctx, body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create event failed: %v", err)
+	}
+	result := NewEvent(created)
+	return &result, nil
+}
+
+/*
+Update 修改事件，参会人会收到更新通知；Attendees 等列表字段为整体替换
+https://learn.microsoft.com/en-us/graph/api/event-update?view=graph-rest-1.0
+*/
+func (c *myCalendar) Update(ctx context.Context, eventId string, event EventInput) (*Event, error) {
+	body, err := event.eventable()
+	if err != nil {
+		return nil, err
+	}
+	client, err := microsoftGraphClient(ctx, c.token)
+	if err != nil {
+		return nil, fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	updated, err := c.user(client).Events().ByEventId(eventId).Patch(ctx, body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("update event failed: %v", err)
+	}
+	result := NewEvent(updated)
+	return &result, nil
+}
+
+/*
+Cancel 组织者取消会议并通知参会人，comment 为取消说明
+https://learn.microsoft.com/en-us/graph/api/event-cancel?view=graph-rest-1.0
+*/
+func (c *myCalendar) Cancel(ctx context.Context, eventId string, comment string) error {
+	client, err := microsoftGraphClient(ctx, c.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	body := graphusers.NewItemEventsItemCancelPostRequestBody()
+	if comment != "" {
+		body.SetComment(&comment)
+	}
+	if err = c.user(client).Events().ByEventId(eventId).Cancel().Post(ctx, body, nil); err != nil {
+		return fmt.Errorf("cancel event failed: %v", err)
+	}
+	return nil
+}
+
+/*
+Accept 接受会议邀请，sendResponse 为 false 时不通知组织者
+https://learn.microsoft.com/en-us/graph/api/event-accept?view=graph-rest-1.0
+*/
+func (c *myCalendar) Accept(ctx context.Context, eventId string, comment string, sendResponse bool) error {
+	client, err := microsoftGraphClient(ctx, c.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	body := graphusers.NewItemEventsItemAcceptPostRequestBody()
+	if comment != "" {
+		body.SetComment(&comment)
+	}
+	body.SetSendResponse(&sendResponse)
+	if err = c.user(client).Events().ByEventId(eventId).Accept().Post(ctx, body, nil); err != nil {
+		return fmt.Errorf("accept event failed: %v", err)
+	}
+	return nil
+}
+
+/*
+TentativelyAccept 暂定接受会议邀请
+https://learn.microsoft.com/en-us/graph/api/event-tentativelyaccept?view=graph-rest-1.0
+*/
+func (c *myCalendar) TentativelyAccept(ctx context.Context, eventId string, comment string, sendResponse bool) error {
+	client, err := microsoftGraphClient(ctx, c.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	body := graphusers.NewItemEventsItemTentativelyacceptTentativelyAcceptPostRequestBody()
+	if comment != "" {
+		body.SetComment(&comment)
+	}
+	body.SetSendResponse(&sendResponse)
+	if err = c.user(client).Events().ByEventId(eventId).TentativelyAccept().Post(ctx, body, nil); err != nil {
+		return fmt.Errorf("tentatively accept event failed: %v", err)
+	}
+	return nil
+}
+
+/*
+Decline 拒绝会议邀请
+https://learn.microsoft.com/en-us/graph/api/event-decline?view=graph-rest-1.0
+*/
+func (c *myCalendar) Decline(ctx context.Context, eventId string, comment string, sendResponse bool) error {
+	client, err := microsoftGraphClient(ctx, c.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	body := graphusers.NewItemEventsItemDeclinePostRequestBody()
+	if comment != "" {
+		body.SetComment(&comment)
+	}
+	body.SetSendResponse(&sendResponse)
+	if err = c.user(client).Events().ByEventId(eventId).Decline().Post(ctx, body, nil); err != nil {
+		return fmt.Errorf("decline event failed: %v", err)
+	}
+	return nil
+}
+
+// eventable 转换为 sdk 的 Event，未设置的字段不会出现在请求中
+func (e EventInput) eventable() (models.Eventable, error) {
+	loc := time.UTC
+	if e.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(e.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid event time zone %s: %v", e.TimeZone, err)
+		}
+	}
+	if !e.Start.IsZero() && !e.End.IsZero() && !e.End.After(e.Start) {
+		return nil, fmt.Errorf("event end must be after start")
+	}
+
+	event := models.NewEvent()
+	if e.Subject != "" {
+		event.SetSubject(&e.Subject)
+	}
+	if e.Body != "" {
+		contentType := models.TEXT_BODYTYPE
+		if e.HTML {
+			contentType = models.HTML_BODYTYPE
+		}
+		body := models.NewItemBody()
+		body.SetContentType(&contentType)
+		body.SetContent(&e.Body)
+		event.SetBody(body)
+	}
+	if !e.Start.IsZero() {
+		event.SetStart(dateTimeTimeZone(e.Start, loc, e.AllDay))
+	}
+	if !e.End.IsZero() {
+		event.SetEnd(dateTimeTimeZone(e.End, loc, e.AllDay))
+	}
+	if e.AllDay {
+		event.SetIsAllDay(&e.AllDay)
+	}
+	if e.Location != "" {
+		location := models.NewLocation()
+		location.SetDisplayName(&e.Location)
+		event.SetLocation(location)
+	}
+	var attendees []models.Attendeeable
+	attendees = append(attendees, eventAttendees(e.Attendees, models.REQUIRED_ATTENDEETYPE)...)
+	attendees = append(attendees, eventAttendees(e.OptionalAttendees, models.OPTIONAL_ATTENDEETYPE)...)
+	attendees = append(attendees, eventAttendees(e.Resources, models.RESOURCE_ATTENDEETYPE)...)
+	if len(attendees) > 0 {
+		event.SetAttendees(attendees)
+	}
+	if e.OnlineMeeting {
+		provider := models.TEAMSFORBUSINESS_ONLINEMEETINGPROVIDERTYPE
+		event.SetIsOnlineMeeting(&e.OnlineMeeting)
+		event.SetOnlineMeetingProvider(&provider)
+	}
+	return event, nil
+}
+
+// dateTimeTimeZone 全天事件只保留日期
+func dateTimeTimeZone(t time.Time, loc *time.Location, allDay bool) models.DateTimeTimeZoneable {
+	t = t.In(loc)
+	if allDay {
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	dateTime := t.Format("2006-01-02T15:04:05")
+	timeZone := loc.String()
+	v := models.NewDateTimeTimeZone()
+	v.SetDateTime(&dateTime)
+	v.SetTimeZone(&timeZone)
+	return v
+}
+
+func eventAttendees(addresses []string, attendeeType models.AttendeeType) []models.Attendeeable {
+	list := make([]models.Attendeeable, 0, len(addresses))
+	for _, address := range addresses {
+		address := address
+		attendeeType := attendeeType
+		email := models.NewEmailAddress()
+		email.SetAddress(&address)
+		attendee := models.NewAttendee()
+		attendee.SetEmailAddress(email)
+		attendee.SetTypeEscaped(&attendeeType)
+		list = append(list, attendee)
+	}
+	return list
+}
+
+// NewEvent 将 sdk 的 Event 转换为 Event
+func NewEvent(item models.Eventable) Event {
+	event := Event{Raw: item}
+	if item == nil {
+		return event
+	}
+	if v := item.GetId(); v != nil {
+		event.ID = *v
+	}
+	if v := item.GetSubject(); v != nil {
+		event.Subject = *v
+	}
+	if body := item.GetBody(); body != nil && body.GetContent() != nil {
+		if t := body.GetContentType(); t != nil && *t == models.HTML_BODYTYPE {
+			event.BodyHTML = *body.GetContent()
+			event.BodyText = htmlText(event.BodyHTML)
+		} else {
+			event.BodyText = *body.GetContent()
+		}
+	}
+	event.Start = parseDateTimeTimeZone(item.GetStart())
+	event.End = parseDateTimeTimeZone(item.GetEnd())
+	if v := item.GetIsAllDay(); v != nil {
+		event.IsAllDay = *v
+	}
+	if v := item.GetLocation(); v != nil && v.GetDisplayName() != nil {
+		event.Location = *v.GetDisplayName()
+	}
+	if v := item.GetOrganizer(); v != nil {
+		event.Organizer = newMailAddress(v)
+	}
+	for _, a := range item.GetAttendees() {
+		attendee := EventAttendee{}
+		if a.GetEmailAddress() != nil {
+			if v := a.GetEmailAddress().GetName(); v != nil {
+				attendee.Name = *v
+			}
+			if v := a.GetEmailAddress().GetAddress(); v != nil {
+				attendee.Address = *v
+			}
+		}
+		if v := a.GetTypeEscaped(); v != nil {
+			attendee.Type = v.String()
+		}
+		if status := a.GetStatus(); status != nil && status.GetResponse() != nil {
+			attendee.Response = status.GetResponse().String()
+		}
+		event.Attendees = append(event.Attendees, attendee)
+	}
+	if v := item.GetIsOnlineMeeting(); v != nil {
+		event.IsOnlineMeeting = *v
+	}
+	if v := item.GetOnlineMeeting(); v != nil && v.GetJoinUrl() != nil {
+		event.OnlineMeetingURL = *v.GetJoinUrl()
+	}
+	if v := item.GetIsCancelled(); v != nil {
+		event.IsCancelled = *v
+	}
+	if v := item.GetTypeEscaped(); v != nil {
+		event.Type = v.String()
+	}
+	if v := item.GetSeriesMasterId(); v != nil {
+		event.SeriesMasterID = *v
+	}
+	if v := item.GetShowAs(); v != nil {
+		event.ShowAs = v.String()
+	}
+	if v := item.GetWebLink(); v != nil {
+		event.WebLink = *v
+	}
+	return event
+}
+
+// parseDateTimeTimeZone 未指定 Prefer: outlook.timezone 时 graph 返回 UTC，其他 IANA 时区按对应时区解析
+func parseDateTimeTimeZone(v models.DateTimeTimeZoneable) time.Time {
+	if v == nil || v.GetDateTime() == nil {
+		return time.Time{}
+	}
+	loc := time.UTC
+	if tz := v.GetTimeZone(); tz != nil && *tz != "" && *tz != "UTC" {
+		if l, err := time.LoadLocation(*tz); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(graphDateTimeLayout, *v.GetDateTime(), loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
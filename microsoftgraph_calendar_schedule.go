@@ -0,0 +1,357 @@
+package msclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultScheduleInterval = 30 * time.Minute
+	DefaultMeetingDuration  = 30 * time.Minute
+)
+
+var ErrNoMeetingTimes = errors.New("no meeting time suggestions")
+
+type Availability string
+
+const (
+	AvailabilityFree             Availability = "free"
+	AvailabilityTentative        Availability = "tentative"
+	AvailabilityBusy             Availability = "busy"
+	AvailabilityOutOfOffice      Availability = "oof"
+	AvailabilityWorkingElsewhere Availability = "workingElsewhere"
+	AvailabilityUnknown          Availability = "unknown"
+)
+
+// availabilityView getSchedule 返回的 availabilityView 中每个字符对应的状态
+var availabilityView = map[rune]Availability{
+	'0': AvailabilityFree,
+	'1': AvailabilityTentative,
+	'2': AvailabilityBusy,
+	'3': AvailabilityOutOfOffice,
+	'4': AvailabilityWorkingElsewhere,
+}
+
+// TimeWindow 时间段 [Start, End)
+type TimeWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// AvailabilitySlot 一段连续相同状态的时间
+type AvailabilitySlot struct {
+	TimeWindow
+	Status Availability `json:"status"`
+}
+
+// ScheduleItem 日历中占用时间的事件，私密事件没有主题和地点
+type ScheduleItem struct {
+	TimeWindow
+	Status    Availability `json:"status"`
+	Subject   string       `json:"subject,omitempty"`
+	Location  string       `json:"location,omitempty"`
+	IsPrivate bool         `json:"isPrivate"`
+}
+
+// Schedule 一个用户、会议室或通讯组的忙闲，Error 不为空时表示无法读取该日历
+type Schedule struct {
+	Email string             `json:"email"`
+	Slots []AvailabilitySlot `json:"slots"`
+	Items []ScheduleItem     `json:"items,omitempty"`
+	Error string             `json:"error,omitempty"`
+}
+
+// MeetingConstraints 会议时间建议的条件
+type MeetingConstraints struct {
+	// Windows 可选的时间段，必填
+	Windows []TimeWindow
+	// Duration 会议时长，默认 DefaultMeetingDuration
+	Duration time.Duration
+	// OptionalAttendees 可选参会人，不影响建议但会返回其忙闲
+	OptionalAttendees []string
+	// Activity 时间范围，work（工作时间，默认）、personal 或 unrestricted
+	Activity string
+	// MaxCandidates 最多返回的建议数
+	MaxCandidates int32
+	// IsOrganizerOptional 为 true 时不考虑组织者的忙闲
+	IsOrganizerOptional bool
+	// MinimumAttendeePercentage 建议时间最低的参会人空闲比例，0-100
+	MinimumAttendeePercentage float64
+}
+
+// AttendeeAvailability 参会人在建议时间的忙闲
+type AttendeeAvailability struct {
+	Address      string       `json:"address"`
+	Availability Availability `json:"availability"`
+}
+
+// MeetingTimeSuggestion 会议时间建议，Confidence 为 0-100 的可出席概率
+type MeetingTimeSuggestion struct {
+	TimeWindow
+	Confidence            float64                `json:"confidence"`
+	OrganizerAvailability Availability           `json:"organizerAvailability"`
+	Attendees             []AttendeeAvailability `json:"attendees"`
+	Locations             []string               `json:"locations,omitempty"`
+	Reason                string                 `json:"reason,omitempty"`
+}
+
+// graphDateTime graph 的 dateTimeTimeZone，请求和响应均使用 UTC
+type graphDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+func newGraphDateTime(t time.Time) graphDateTime {
+	return graphDateTime{DateTime: t.UTC().Format("2006-01-02T15:04:05"), TimeZone: "UTC"}
+}
+
+func (d graphDateTime) time() time.Time {
+	t, err := time.ParseInLocation(graphDateTimeLayout, d.DateTime, time.UTC)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+/*
+GetSchedule 读取多个用户、会议室或通讯组在 window 内的忙闲，interval 为 Slots 的粒度，范围 5 分钟到 1 天，默认 DefaultScheduleInterval；
+相邻相同状态的时间段会合并为一个 Slot
+https://learn.microsoft.com/en-us/graph/api/calendar-getschedule?view=graph-rest-1.0
+*/
+func (c *myCalendar) GetSchedule(ctx context.Context, emails []string, window TimeWindow, interval time.Duration) ([]Schedule, error) {
+	if len(emails) == 0 {
+		return nil, fmt.Errorf("missing schedule emails")
+	}
+	if !window.End.After(window.Start) {
+		return nil, fmt.Errorf("schedule window end must be after start")
+	}
+	if interval <= 0 {
+		interval = DefaultScheduleInterval
+	}
+	if interval < 5*time.Minute || interval > 24*time.Hour {
+		return nil, fmt.Errorf("schedule interval must be between 5 minutes and 1 day")
+	}
+
+	payload := map[string]any{
+		"schedules":                emails,
+		"startTime":                newGraphDateTime(window.Start),
+		"endTime":                  newGraphDateTime(window.End),
+		"availabilityViewInterval": int(interval / time.Minute),
+	}
+	var resp struct {
+		Value []struct {
+			ScheduleID       string `json:"scheduleId"`
+			AvailabilityView string `json:"availabilityView"`
+			ScheduleItems    []struct {
+				IsPrivate bool          `json:"isPrivate"`
+				Status    Availability  `json:"status"`
+				Subject   string        `json:"subject"`
+				Location  string        `json:"location"`
+				Start     graphDateTime `json:"start"`
+				End       graphDateTime `json:"end"`
+			} `json:"scheduleItems"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"value"`
+	}
+	u := fmt.Sprintf("%s/v1.0%s/calendar/getSchedule", GraphAPIHost, c.userPath())
+	if err := c.post(ctx, u, payload, &resp); err != nil {
+		return nil, fmt.Errorf("get schedule failed: %v", err)
+	}
+
+	schedules := make([]Schedule, 0, len(resp.Value))
+	for _, v := range resp.Value {
+		schedule := Schedule{Email: v.ScheduleID, Slots: availabilitySlots(v.AvailabilityView, window, interval)}
+		if v.Error != nil {
+			schedule.Error = v.Error.Message
+		}
+		for _, item := range v.ScheduleItems {
+			schedule.Items = append(schedule.Items, ScheduleItem{
+				TimeWindow: TimeWindow{Start: item.Start.time(), End: item.End.time()},
+				Status:     item.Status,
+				Subject:    item.Subject,
+				Location:   item.Location,
+				IsPrivate:  item.IsPrivate,
+			})
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+/*
+FindMeetingTimes 根据参会人和组织者的忙闲建议会议时间，没有可用时间时返回 ErrNoMeetingTimes 及原因
+https://learn.microsoft.com/en-us/graph/api/user-findmeetingtimes?view=graph-rest-1.0
+*/
+func (c *myCalendar) FindMeetingTimes(ctx context.Context, attendees []string, constraints MeetingConstraints) ([]MeetingTimeSuggestion, error) {
+	if len(constraints.Windows) == 0 {
+		return nil, fmt.Errorf("missing meeting time windows")
+	}
+	if constraints.Duration <= 0 {
+		constraints.Duration = DefaultMeetingDuration
+	}
+	if constraints.Activity == "" {
+		constraints.Activity = "work"
+	}
+
+	type emailAddress struct {
+		Address string `json:"address"`
+	}
+	type attendee struct {
+		Type         string       `json:"type"`
+		EmailAddress emailAddress `json:"emailAddress"`
+	}
+	type timeSlot struct {
+		Start graphDateTime `json:"start"`
+		End   graphDateTime `json:"end"`
+	}
+	list := make([]attendee, 0, len(attendees)+len(constraints.OptionalAttendees))
+	for _, address := range attendees {
+		list = append(list, attendee{Type: "required", EmailAddress: emailAddress{Address: address}})
+	}
+	for _, address := range constraints.OptionalAttendees {
+		list = append(list, attendee{Type: "optional", EmailAddress: emailAddress{Address: address}})
+	}
+	slots := make([]timeSlot, 0, len(constraints.Windows))
+	for _, w := range constraints.Windows {
+		if !w.End.After(w.Start) {
+			return nil, fmt.Errorf("meeting time window end must be after start")
+		}
+		slots = append(slots, timeSlot{Start: newGraphDateTime(w.Start), End: newGraphDateTime(w.End)})
+	}
+
+	payload := map[string]any{
+		"attendees": list,
+		"timeConstraint": map[string]any{
+			"activityDomain": constraints.Activity,
+			"timeSlots":      slots,
+		},
+		"meetingDuration":         iso8601Duration(constraints.Duration),
+		"isOrganizerOptional":     constraints.IsOrganizerOptional,
+		"returnSuggestionReasons": true,
+	}
+	if constraints.MaxCandidates > 0 {
+		payload["maxCandidates"] = constraints.MaxCandidates
+	}
+	if constraints.MinimumAttendeePercentage > 0 {
+		payload["minimumAttendeePercentage"] = constraints.MinimumAttendeePercentage
+	}
+
+	var resp struct {
+		EmptySuggestionsReason string `json:"emptySuggestionsReason"`
+		MeetingTimeSuggestions []struct {
+			Confidence            float64      `json:"confidence"`
+			OrganizerAvailability Availability `json:"organizerAvailability"`
+			SuggestionReason      string       `json:"suggestionReason"`
+			MeetingTimeSlot       timeSlot     `json:"meetingTimeSlot"`
+			AttendeeAvailability  []struct {
+				Attendee     attendee     `json:"attendee"`
+				Availability Availability `json:"availability"`
+			} `json:"attendeeAvailability"`
+			Locations []struct {
+				DisplayName string `json:"displayName"`
+			} `json:"locations"`
+		} `json:"meetingTimeSuggestions"`
+	}
+	u := fmt.Sprintf("%s/v1.0%s/findMeetingTimes", GraphAPIHost, c.userPath())
+	if err := c.post(ctx, u, payload, &resp); err != nil {
+		return nil, fmt.Errorf("find meeting times failed: %v", err)
+	}
+	if len(resp.MeetingTimeSuggestions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoMeetingTimes, resp.EmptySuggestionsReason)
+	}
+
+	suggestions := make([]MeetingTimeSuggestion, 0, len(resp.MeetingTimeSuggestions))
+	for _, v := range resp.MeetingTimeSuggestions {
+		suggestion := MeetingTimeSuggestion{
+			TimeWindow:            TimeWindow{Start: v.MeetingTimeSlot.Start.time(), End: v.MeetingTimeSlot.End.time()},
+			Confidence:            v.Confidence,
+			OrganizerAvailability: v.OrganizerAvailability,
+			Reason:                v.SuggestionReason,
+		}
+		for _, a := range v.AttendeeAvailability {
+			suggestion.Attendees = append(suggestion.Attendees, AttendeeAvailability{
+				Address:      a.Attendee.EmailAddress.Address,
+				Availability: a.Availability,
+			})
+		}
+		for _, l := range v.Locations {
+			if l.DisplayName != "" {
+				suggestion.Locations = append(suggestion.Locations, l.DisplayName)
+			}
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
+
+// availabilitySlots 将 availabilityView 按状态合并为连续的时间段；graph 按分钟对齐 startTime，时间段也从对齐后的时间开始
+func availabilitySlots(view string, window TimeWindow, interval time.Duration) []AvailabilitySlot {
+	var slots []AvailabilitySlot
+	start := window.Start.UTC().Truncate(time.Minute)
+	end := window.End.UTC()
+	i := 0
+	for _, r := range view {
+		slotStart := start.Add(time.Duration(i) * interval)
+		if !slotStart.Before(end) {
+			break
+		}
+		i++
+		status, ok := availabilityView[r]
+		if !ok {
+			status = AvailabilityUnknown
+		}
+		slotEnd := slotStart.Add(interval)
+		if slotEnd.After(end) {
+			slotEnd = end
+		}
+		if n := len(slots); n > 0 && slots[n-1].Status == status {
+			slots[n-1].End = slotEnd
+			continue
+		}
+		slots = append(slots, AvailabilitySlot{
+			TimeWindow: TimeWindow{Start: slotStart, End: slotEnd},
+			Status:     status,
+		})
+	}
+	return slots
+}
+
+// iso8601Duration 转换为 PT1H30M 格式
+func iso8601Duration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes%60 == 0 {
+		return fmt.Sprintf("PT%dH", minutes/60)
+	}
+	if minutes > 60 {
+		return fmt.Sprintf("PT%dH%dM", minutes/60, minutes%60)
+	}
+	return fmt.Sprintf("PT%dM", minutes)
+}
+
+// post 以 json 发送 payload 并将响应解析到 v
+func (c *myCalendar) post(ctx context.Context, u string, payload any, v any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding request: %v", err)
+	}
+	body, err := graphRequest(ctx, c.token, http.MethodPost, u, bytes.NewReader(b), map[string][]string{
+		"Content-Type": {"application/json"},
+	})
+	if err != nil {
+		return err
+	}
+	if err = checkApiError(body); err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("error decoding response: %v", err)
+	}
+	return nil
+}
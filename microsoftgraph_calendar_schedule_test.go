@@ -0,0 +1,113 @@
+package msclient
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAvailabilitySlots(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 1, hour, minute, 0, 0, time.UTC)
+	}
+	slot := func(start, end time.Time, status Availability) AvailabilitySlot {
+		return AvailabilitySlot{TimeWindow: TimeWindow{Start: start, End: end}, Status: status}
+	}
+	tests := []struct {
+		name     string
+		view     string
+		window   TimeWindow
+		interval time.Duration
+		want     []AvailabilitySlot
+	}{
+		{
+			name:     "empty",
+			view:     "",
+			window:   TimeWindow{Start: at(9, 0), End: at(10, 0)},
+			interval: 30 * time.Minute,
+			want:     nil,
+		},
+		{
+			name:     "merge same status",
+			view:     "00220",
+			window:   TimeWindow{Start: at(9, 0), End: at(11, 30)},
+			interval: 30 * time.Minute,
+			want: []AvailabilitySlot{
+				slot(at(9, 0), at(10, 0), AvailabilityFree),
+				slot(at(10, 0), at(11, 0), AvailabilityBusy),
+				slot(at(11, 0), at(11, 30), AvailabilityFree),
+			},
+		},
+		{
+			name:     "all statuses and unknown",
+			view:     "01234x",
+			window:   TimeWindow{Start: at(9, 0), End: at(12, 0)},
+			interval: 30 * time.Minute,
+			want: []AvailabilitySlot{
+				slot(at(9, 0), at(9, 30), AvailabilityFree),
+				slot(at(9, 30), at(10, 0), AvailabilityTentative),
+				slot(at(10, 0), at(10, 30), AvailabilityBusy),
+				slot(at(10, 30), at(11, 0), AvailabilityOutOfOffice),
+				slot(at(11, 0), at(11, 30), AvailabilityWorkingElsewhere),
+				slot(at(11, 30), at(12, 0), AvailabilityUnknown),
+			},
+		},
+		{
+			name:     "last slot capped at window end",
+			view:     "02",
+			window:   TimeWindow{Start: at(9, 0), End: at(9, 45)},
+			interval: 30 * time.Minute,
+			want: []AvailabilitySlot{
+				slot(at(9, 0), at(9, 30), AvailabilityFree),
+				slot(at(9, 30), at(9, 45), AvailabilityBusy),
+			},
+		},
+		{
+			name:     "view longer than window",
+			view:     "0022",
+			window:   TimeWindow{Start: at(9, 0), End: at(10, 0)},
+			interval: 30 * time.Minute,
+			want: []AvailabilitySlot{
+				slot(at(9, 0), at(10, 0), AvailabilityFree),
+			},
+		},
+		{
+			name:     "start truncated to minute and converted to utc",
+			view:     "2",
+			window:   TimeWindow{Start: at(9, 0).Add(30 * time.Second).In(time.FixedZone("CST", 8*3600)), End: at(9, 30)},
+			interval: 30 * time.Minute,
+			want: []AvailabilitySlot{
+				slot(at(9, 0), at(9, 30), AvailabilityBusy),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := availabilitySlots(tt.view, tt.window, tt.interval)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("availabilitySlots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestISO8601Duration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Minute, "PT30M"},
+		{time.Hour, "PT1H"},
+		{90 * time.Minute, "PT1H30M"},
+		{2 * time.Hour, "PT2H"},
+		{125 * time.Minute, "PT2H5M"},
+		{29*time.Minute + 40*time.Second, "PT30M"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := iso8601Duration(tt.d); got != tt.want {
+				t.Errorf("iso8601Duration(%v) = %q, want %q", tt.d, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package msclient
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Drive 文件上传、列表、下载的目标 drive，所有路径都基于同一个 drive 拼接
+type Drive struct {
+	path string
+}
+
+// MyDrive 当前登录用户的 OneDrive
+func MyDrive() Drive {
+	return Drive{path: "/me/drive"}
+}
+
+// UserDrive 指定用户的 OneDrive for Business
+func UserDrive(userId string) Drive {
+	return Drive{path: fmt.Sprintf("/users/%s/drive", url.PathEscape(userId))}
+}
+
+// GroupDrive Microsoft 365 组的文档库
+func GroupDrive(groupId string) Drive {
+	return Drive{path: fmt.Sprintf("/groups/%s/drive", url.PathEscape(groupId))}
+}
+
+// SiteDrive 站点下的文档库，driveId 为空时使用站点默认文档库
+func SiteDrive(siteId string, driveId string) Drive {
+	if driveId == "" {
+		return Drive{path: fmt.Sprintf("/sites/%s/drive", siteId)}
+	}
+	return Drive{path: fmt.Sprintf("/sites/%s/drives/%s", siteId, driveId)}
+}
+
+func (d Drive) String() string {
+	return d.path
+}
+
+// url 拼接 drive 下的 api 地址，如 d.url("/items/%s/children", id)
+func (d Drive) url(format string, args ...any) string {
+	return fmt.Sprintf("%s/v1.0%s", GraphAPIHost, d.path) + fmt.Sprintf(format, args...)
+}
+
+// DownloadUrl 文件内容的下载地址，可直接传给 SharePoint.Download
+func (d Drive) DownloadUrl(itemId string) string {
+	return d.url("/items/%s/content", itemId)
+}
+
+// OpenDrive 在指定 drive 上进行文件操作
+func (c *MicrosoftGraph) OpenDrive(token Token, drive Drive) SharePoint {
+	return &mySharePoint{token: token, drive: drive}
+}
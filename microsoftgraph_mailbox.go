@@ -3,58 +3,320 @@ package msclient
 import (
 	"context"
 	"fmt"
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
 	"github.com/microsoftgraph/msgraph-sdk-go/models"
 	graphusers "github.com/microsoftgraph/msgraph-sdk-go/users"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
 )
 
+const (
+	DefaultMessagePageSize  = 50
+	DefaultHydrateWorkers   = 4
+	MessageExpandAttachment = "attachments"
+)
+
+// defaultMailProperties 邮件的默认字段，Hydrate 时未指定 Properties 则和 Hydrate 字段一起请求
+var defaultMailProperties = []string{
+	"id", "createdDateTime", "lastModifiedDateTime", "changeKey", "categories", "receivedDateTime", "sentDateTime",
+	"hasAttachments", "internetMessageId", "subject", "bodyPreview", "importance", "parentFolderId", "conversationId",
+	"conversationIndex", "isDeliveryReceiptRequested", "isReadReceiptRequested", "isRead", "isDraft", "webLink",
+	"inferenceClassification", "body", "sender", "from", "toRecipients", "ccRecipients", "bccRecipients", "replyTo", "flag",
+}
+
+// MyMailBox 登录用户的邮箱，需要委托权限
 func (c *MicrosoftGraph) MyMailBox(token Token, mailProperties ...string) MailBox {
 	return &myMailBox{token: token, mailProperties: mailProperties}
 }
 
+// UserMailBox 指定用户的邮箱，userId 为用户 id 或 userPrincipalName
+func (c *MicrosoftGraph) UserMailBox(token Token, userId string, mailProperties ...string) MailBox {
+	return &myMailBox{token: token, userId: userId, mailProperties: mailProperties}
+}
+
+// SharedMailBox 共享邮箱，address 为共享邮箱地址，如 support@contoso.com；
+// graph 中共享邮箱就是一个用户，与 UserMailBox(token, address) 完全相同，仅为调用处可读性保留。
+// 使用登录用户的 token 时需要该用户拥有该邮箱的完全访问权限
+func (c *MicrosoftGraph) SharedMailBox(token Token, address string, mailProperties ...string) MailBox {
+	return c.UserMailBox(token, address, mailProperties...)
+}
+
 type MailBox interface {
 	GetMails(ctx context.Context, size int32) (Mails, error)
+	Messages(ctx context.Context, opts MessageOptions) *Pager[models.Messageable]
+	Message(ctx context.Context, messageId string, properties ...string) (models.Messageable, error)
+	Folders(ctx context.Context) ([]MailFolderInfo, error)
+	Folder(nameOrId string) MailFolder
+	CreateFolder(ctx context.Context, parent string, name string) (*MailFolderInfo, error)
+	RenameFolder(ctx context.Context, folder string, name string) error
+	DeleteFolder(ctx context.Context, folder string) error
+	Send(ctx context.Context, msg Message) error
+	Reply(ctx context.Context, messageId string, msg Message) error
+	ReplyAll(ctx context.Context, messageId string, msg Message) error
+	Forward(ctx context.Context, messageId string, msg Message) error
+	CreateDraft(ctx context.Context, msg Message) (string, error)
+	UpdateDraft(ctx context.Context, draftId string, msg Message) error
+	AddAttachment(ctx context.Context, draftId string, attachment Attachment) (string, error)
+	SendDraft(ctx context.Context, draftId string) error
+	AddLargeAttachment(ctx context.Context, draftId string, name string, r io.Reader, size int64) (string, error)
+	Attachments(ctx context.Context, messageId string) ([]MailAttachment, error)
+	DownloadAttachment(ctx context.Context, messageId string, attachmentId string, w io.Writer) error
+	ExportMIME(ctx context.Context, messageId string, w io.Writer) error
+	ExportFolder(ctx context.Context, folder string, dir string) (int, error)
+	ExportArchive(ctx context.Context, folder string, format ArchiveFormat, dir string) (int, error)
+	ImportArchive(ctx context.Context, parent string, format ArchiveFormat, dir string) (int, error)
+	ImportEML(ctx context.Context, folder string, r io.Reader) (string, error)
+	ImportMbox(ctx context.Context, folder string, r io.Reader) (int, error)
+	ImportMaildir(ctx context.Context, folder string, dir string) (int, error)
+	Conversations(ctx context.Context, folder string, opts MessageOptions) ([]Conversation, error)
+	MarkRead(ctx context.Context, messageId string, read bool) error
+	Flag(ctx context.Context, messageId string) error
+	Complete(ctx context.Context, messageId string) error
+	Unflag(ctx context.Context, messageId string) error
+	SetCategories(ctx context.Context, messageId string, categories []string) error
+	Move(ctx context.Context, messageId string, destFolder string) (string, error)
+	Copy(ctx context.Context, messageId string, destFolder string) (string, error)
+	Delete(ctx context.Context, messageId string, permanent bool) error
+}
+
+type MessageOptions struct {
+	// PageSize 每页条数，默认 DefaultMessagePageSize
+	PageSize int32
+	// Max 最多返回条数，<= 0 时读取全部邮件
+	Max int
+	// Properties 需要返回的字段，为空时使用 MyMailBox 传入的 mailProperties，都为空时返回默认字段（含 body）
+	Properties []string
+	// ExpandAttachments 列表请求中 $expand=attachments，一次取回附件
+	ExpandAttachments bool
+	// Hydrate 列表接口无法返回的字段，会逐封邮件单独请求后替换列表中的结果
+	Hydrate []string
+	// Workers 逐封请求的并发数，默认 DefaultHydrateWorkers
+	Workers int
+	// Query 过滤、搜索和排序条件
+	Query *MailQuery
 }
 
 type myMailBox struct {
 	mailProperties []string
 	token          Token
+	// userId 为空时为登录用户 /me
+	userId string
+	// folderIds 已解析的文件夹名称或路径到 id，重命名、删除文件夹时清空
+	folderIds sync.Map
 }
 
+// GetMails 读取 size 封邮件，读取全部邮件使用 Messages
 func (m *myMailBox) GetMails(ctx context.Context, size int32) (Mails, error) {
-
-	requestParameters := &graphusers.ItemMessagesRequestBuilderGetQueryParameters{
-		Top:    &size,
-		Select: m.mailProperties,
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid mail size %d, must be greater than 0", size)
 	}
-	configuration := &graphusers.ItemMessagesRequestBuilderGetRequestConfiguration{
-		QueryParameters: requestParameters,
+	messages, err := m.Messages(ctx, MessageOptions{PageSize: size, Max: int(size)}).All(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return messages, nil
+}
+
+/*
+Messages 分页读取全部文件夹的邮件，自动跟随 @odata.nextLink
+https://learn.microsoft.com/en-us/graph/api/user-list-messages?view=graph-rest-1.0
+*/
+func (m *myMailBox) Messages(ctx context.Context, opts MessageOptions) *Pager[models.Messageable] {
+	return m.messages(func(ctx context.Context) (string, error) { return "", nil }, opts)
+}
 
+/*
+Message 按 id 读取一封邮件，properties 为空时使用 MyMailBox 传入的 mailProperties，都为空时返回默认字段
+https://learn.microsoft.com/en-us/graph/api/message-get?view=graph-rest-1.0
+*/
+func (m *myMailBox) Message(ctx context.Context, messageId string, properties ...string) (models.Messageable, error) {
 	client, err := microsoftGraphClient(ctx, m.token)
 	if err != nil {
 		return nil, fmt.Errorf("token to ms graph client failed:%v", err)
 	}
-	mailBox := client.Me().Messages()
-	messages, err := mailBox.Get(ctx, configuration)
+	if len(properties) == 0 {
+		properties = m.mailProperties
+	}
+	var configuration *graphusers.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration
+	if len(properties) > 0 {
+		configuration = &graphusers.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
+			QueryParameters: &graphusers.ItemMessagesMessageItemRequestBuilderGetQueryParameters{Select: properties},
+		}
+	}
+	message, err := m.user(client).Messages().ByMessageId(messageId).Get(ctx, configuration)
 	if err != nil {
-		return nil, fmt.Errorf("connect outlook mailbox failed: %v", err)
+		return nil, fmt.Errorf("get mail failed: %v", err)
+	}
+	return message, nil
+}
+
+// user 邮箱所属用户
+func (m *myMailBox) user(client *msgraphsdk.GraphServiceClient) *graphusers.UserItemRequestBuilder {
+	if m.userId != "" {
+		return client.Users().ByUserId(m.userId)
+	}
+	return client.Me()
+}
+
+// userPath 邮箱所属用户的 api 路径
+func (m *myMailBox) userPath() string {
+	if m.userId != "" {
+		return "/users/" + url.PathEscape(m.userId)
 	}
+	return "/me"
+}
+
+// messages folderId 返回空字符串时读取全部邮件
+func (m *myMailBox) messages(folderId func(ctx context.Context) (string, error), opts MessageOptions) *Pager[models.Messageable] {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultMessagePageSize
+	}
+	if opts.Max > 0 && opts.Max < int(opts.PageSize) {
+		opts.PageSize = int32(opts.Max)
+	}
+	if len(opts.Properties) == 0 {
+		opts.Properties = m.mailProperties
+	}
+	var expand []string
+	if opts.ExpandAttachments {
+		expand = []string{MessageExpandAttachment}
+	}
+
+	var nextLink string
+	return newPager(opts.Max, func(ctx context.Context) ([]models.Messageable, bool, error) {
+		client, err := microsoftGraphClient(ctx, m.token)
+		if err != nil {
+			return nil, false, fmt.Errorf("token to ms graph client failed:%v", err)
+		}
+		mailBox := m.user(client).Messages()
+
+		if nextLink == "" {
+			folder, err := folderId(ctx)
+			if err != nil {
+				return nil, false, err
+			}
+			query := [][2]string{{"$top", fmt.Sprintf("%d", opts.PageSize)}}
+			if len(opts.Properties) > 0 {
+				query = append(query, [2]string{"$select", strings.Join(opts.Properties, ",")})
+			}
+			if len(expand) > 0 {
+				query = append(query, [2]string{"$expand", strings.Join(expand, ",")})
+			}
+			params, err := opts.Query.params()
+			if err != nil {
+				return nil, false, err
+			}
+			query = append(query, params...)
+			nextLink = m.messagesUrl(folder) + "?" + odataQuery(query)
+		}
+		messages, err := mailBox.WithUrl(nextLink).Get(ctx, nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("connect outlook mailbox failed: %v", err)
+		}
+
+		var page []models.Messageable
+		for _, message := range messages.GetValue() {
+			if message != nil {
+				page = append(page, message)
+			}
+		}
+		nextLink = ""
+		if next := messages.GetOdataNextLink(); next != nil {
+			nextLink = *next
+		}
+
+		if len(opts.Hydrate) > 0 {
+			if err = m.hydrate(ctx, mailBox, page, opts, expand); err != nil {
+				return nil, false, err
+			}
+		}
+		return page, nextLink != "", nil
+	})
+}
+
+// hydrate 使用有限的并发逐封请求 Hydrate 字段，结果按原顺序写回 page
+func (m *myMailBox) hydrate(ctx context.Context, mailBox *graphusers.ItemMessagesRequestBuilder, page []models.Messageable, opts MessageOptions, expand []string) error {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultHydrateWorkers
+	}
+	properties := opts.Properties
+	if len(properties) == 0 {
+		properties = defaultMailProperties
+	}
+	properties = append(append([]string{}, properties...), opts.Hydrate...)
+	configuration := &graphusers.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &graphusers.ItemMessagesMessageItemRequestBuilderGetQueryParameters{
+			Select: properties,
+			Expand: expand,
+		},
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	var (
-		mails = make(Mails, size)
-		i     int
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+		jobs  = make(chan int)
 	)
-	for _, message := range messages.GetValue() {
-		if id := message.GetId(); id != nil {
-			message, err = mailBox.ByMessageId(*id).Get(ctx, nil)
-			if err != nil {
-				return nil, fmt.Errorf("get mail detail failed:%v", err)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				id := page[i].GetId()
+				if id == nil {
+					continue
+				}
+				message, err := mailBox.ByMessageId(*id).Get(ctx, configuration)
+				if err != nil {
+					once.Do(func() {
+						first = fmt.Errorf("get mail detail failed:%v", err)
+						cancel()
+					})
+					continue
+				}
+				page[i] = message
 			}
-			mails[i] = message
-			i++
+		}()
+	}
+dispatch:
+	for i := range page {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
 		}
 	}
-	return mails, nil
+	close(jobs)
+	wg.Wait()
+	if first == nil && parent.Err() != nil {
+		// 调用方取消时剩余的邮件没有读取，不能返回部分替换的结果
+		return parent.Err()
+	}
+	return first
+}
+
+// messagesUrl 邮件列表地址，folderId 为空时为全部邮件
+func (m *myMailBox) messagesUrl(folderId string) string {
+	if folderId == "" {
+		return fmt.Sprintf("%s/v1.0%s/messages", GraphAPIHost, m.userPath())
+	}
+	return fmt.Sprintf("%s/v1.0%s/mailFolders/%s/messages", GraphAPIHost, m.userPath(), url.PathEscape(folderId))
+}
+
+// odataQuery 拼接 odata 查询参数，$ 开头的参数名不做转义，空格转义为 %20
+func odataQuery(params [][2]string) string {
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		parts = append(parts, p[0]+"="+strings.ReplaceAll(url.QueryEscape(p[1]), "+", "%20"))
+	}
+	return strings.Join(parts, "&")
 }
 
 type Mails []models.Messageable
@@ -0,0 +1,486 @@
+package msclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ArchiveFormat string
+
+const (
+	// ArchiveMbox 每个文件夹一个 mboxrd 文件，如 Inbox/Support.mbox
+	ArchiveMbox ArchiveFormat = "mbox"
+	// ArchiveMaildir 每个文件夹一个 Maildir 目录，如 Inbox/Support/{cur,new,tmp}
+	ArchiveMaildir ArchiveFormat = "maildir"
+)
+
+// ArchiveFoldersFile ExportArchive 在导出目录中记录目录与文件夹显示名称的对应关系，ImportArchive 按原名称还原文件夹
+const ArchiveFoldersFile = ".folders.json"
+
+var mboxFromEscaped = regexp.MustCompile(`^>+From `)
+
+// archiveFolder 导出的文件夹及其相对导出目录的路径（以 / 分隔）
+type archiveFolder struct {
+	info MailFolderInfo
+	path string
+}
+
+/*
+ExportArchive 将文件夹及其子文件夹导出为 mbox 或 Maildir，folder 为空时导出整个邮箱，目录结构与文件夹层级一致，返回导出的邮件数量；
+目录名由显示名称转换而来，原名称记录在 ArchiveFoldersFile
+*/
+func (m *myMailBox) ExportArchive(ctx context.Context, folder string, format ArchiveFormat, dir string) (int, error) {
+	if format != ArchiveMbox && format != ArchiveMaildir {
+		return 0, fmt.Errorf("unknown archive format: %s", format)
+	}
+	folders, err := m.Folders(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if folder != "" {
+		id, err := m.Folder(folder).ID(ctx)
+		if err != nil {
+			return 0, err
+		}
+		found := findMailFolderById(folders, id)
+		if found == nil {
+			return 0, fmt.Errorf("mail folder %s not found", folder)
+		}
+		folders = []MailFolderInfo{*found}
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	targets, names := archivePaths(folders)
+	b, err := json.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err = os.WriteFile(filepath.Join(dir, ArchiveFoldersFile), b, 0644); err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, target := range targets {
+		n, err := m.exportArchiveFolder(ctx, target.info, format, filepath.Join(dir, filepath.FromSlash(target.path)))
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// archivePaths 按文件夹层级生成导出路径，每一级显示名称都转换为安全的目录名，同级重名时加序号；
+// names 为路径到原显示名称的对应关系
+func archivePaths(folders []MailFolderInfo) ([]archiveFolder, map[string]string) {
+	var (
+		targets []archiveFolder
+		names   = map[string]string{}
+	)
+	var walk func(folders []MailFolderInfo, parent string)
+	walk = func(folders []MailFolderInfo, parent string) {
+		used := map[string]bool{}
+		for _, f := range folders {
+			path := strings.TrimPrefix(parent+"/"+archiveDirName(f.DisplayName, used), "/")
+			names[path] = f.DisplayName
+			targets = append(targets, archiveFolder{info: f, path: path})
+			walk(f.Children, path)
+		}
+	}
+	walk(folders, "")
+	return targets, names
+}
+
+// archiveDirName 显示名称转换为目录名，. 和 .. 替换为 _，used 中已有的名称（不区分大小写）加序号
+func archiveDirName(displayName string, used map[string]bool) string {
+	name := safeFileName(displayName)
+	if strings.Trim(name, ".") == "" {
+		name = strings.Repeat("_", len(name))
+	}
+	base := name
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func findMailFolderById(folders []MailFolderInfo, id string) *MailFolderInfo {
+	for i := range folders {
+		if folders[i].ID == id || folders[i].WellKnownName == id {
+			return &folders[i]
+		}
+		if found := findMailFolderById(folders[i].Children, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func (m *myMailBox) exportArchiveFolder(ctx context.Context, folder MailFolderInfo, format ArchiveFormat, path string) (int, error) {
+	var mbox *os.File
+	if format == ArchiveMbox {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return 0, err
+		}
+		f, err := os.Create(path + ".mbox")
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		mbox = f
+	} else {
+		for _, sub := range []string{"cur", "new", "tmp"} {
+			if err := os.MkdirAll(filepath.Join(path, sub), 0755); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var count int
+	pager := m.Folder(folder.ID).Messages(ctx, MessageOptions{
+		PageSize:   100,
+		Properties: []string{"id", "from", "receivedDateTime", "isRead"},
+	})
+	for pager.HasNext() {
+		messages, err := pager.Next(ctx)
+		if err != nil {
+			return count, err
+		}
+		for _, message := range messages {
+			id := message.GetId()
+			if id == nil {
+				continue
+			}
+			var (
+				buf      bytes.Buffer
+				sender   = "MAILER-DAEMON"
+				received = time.Now()
+				isRead   bool
+			)
+			if err = m.ExportMIME(ctx, *id, &buf); err != nil {
+				return count, fmt.Errorf("export mail %s failed: %v", *id, err)
+			}
+			if from := message.GetFrom(); from != nil && from.GetEmailAddress() != nil && from.GetEmailAddress().GetAddress() != nil {
+				sender = *from.GetEmailAddress().GetAddress()
+			}
+			if v := message.GetReceivedDateTime(); v != nil {
+				received = *v
+			}
+			if v := message.GetIsRead(); v != nil {
+				isRead = *v
+			}
+
+			if mbox != nil {
+				err = writeMbox(mbox, sender, received, buf.Bytes())
+			} else {
+				err = writeMaildir(path, *id, received, isRead, buf.Bytes())
+			}
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// writeMbox 按 mboxrd 格式写入，正文中以 From 开头的行前加 >
+func writeMbox(w io.Writer, sender string, received time.Time, mime []byte) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "From %s %s\n", sender, received.UTC().Format(time.ANSIC))
+	lines := strings.Split(strings.ReplaceAll(string(mime), "\r\n", "\n"), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "From ") || mboxFromEscaped.MatchString(line) {
+			bw.WriteString(">")
+		}
+		bw.WriteString(line)
+		bw.WriteString("\n")
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// writeMaildir 先写入 tmp 再移动到 cur，已读邮件带 S 标记
+func writeMaildir(dir string, id string, received time.Time, isRead bool, mime []byte) error {
+	sum := sha1.Sum([]byte(id))
+	name := fmt.Sprintf("%d.%s.msclient", received.Unix(), hex.EncodeToString(sum[:8]))
+	tmp := filepath.Join(dir, "tmp", name)
+	if err := os.WriteFile(tmp, mime, 0644); err != nil {
+		return err
+	}
+	flags := ":2,"
+	if isRead {
+		flags += "S"
+	}
+	return os.Rename(tmp, filepath.Join(dir, "cur", name+flags))
+}
+
+/*
+ImportEML 以 MIME 格式上传邮件到文件夹，返回新邮件 id，graph 创建的邮件会带有草稿标记
+https://learn.microsoft.com/en-us/graph/api/mailfolder-post-messages?view=graph-rest-1.0
+*/
+func (m *myMailBox) ImportEML(ctx context.Context, folder string, r io.Reader) (string, error) {
+	folderId, err := m.Folder(folder).ID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return m.importEML(ctx, folderId, r)
+}
+
+// importEML 上传邮件到 folderId，批量导入时先解析一次文件夹 id，避免每封邮件都查询文件夹
+func (m *myMailBox) importEML(ctx context.Context, folderId string, r io.Reader) (string, error) {
+	mime, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("read eml failed: %v", err)
+	}
+	u := fmt.Sprintf("%s/v1.0%s/mailFolders/%s/messages", GraphAPIHost, m.userPath(), url.PathEscape(folderId))
+	body, err := graphRequest(ctx, m.token, http.MethodPost, u, strings.NewReader(base64.StdEncoding.EncodeToString(mime)), map[string][]string{
+		"Content-Type": {"text/plain"},
+	})
+	if err != nil {
+		return "", err
+	}
+	if err = checkApiError(body); err != nil {
+		return "", err
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err = json.Unmarshal(body, &created); err != nil {
+		return "", fmt.Errorf("error decoding message: %v", err)
+	}
+	return created.ID, nil
+}
+
+// ImportMbox 导入 mbox 文件中的全部邮件到文件夹，返回导入数量
+func (m *myMailBox) ImportMbox(ctx context.Context, folder string, r io.Reader) (int, error) {
+	folderId, err := m.Folder(folder).ID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return m.importMbox(ctx, folderId, r)
+}
+
+func (m *myMailBox) importMbox(ctx context.Context, folderId string, r io.Reader) (int, error) {
+	var count int
+	err := readMbox(r, func(mime []byte) error {
+		if _, err := m.importEML(ctx, folderId, bytes.NewReader(mime)); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// ImportMaildir 导入 Maildir 目录 cur 和 new 中的邮件到文件夹，返回导入数量
+func (m *myMailBox) ImportMaildir(ctx context.Context, folder string, dir string) (int, error) {
+	folderId, err := m.Folder(folder).ID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return m.importMaildir(ctx, folderId, dir)
+}
+
+func (m *myMailBox) importMaildir(ctx context.Context, folderId string, dir string) (int, error) {
+	var files []string
+	for _, sub := range []string{"cur", "new"} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(dir, sub, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+
+	var count int
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return count, err
+		}
+		_, err = m.importEML(ctx, folderId, f)
+		f.Close()
+		if err != nil {
+			return count, fmt.Errorf("import %s failed: %v", file, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+/*
+ImportArchive 导入 ExportArchive 生成的目录到 parent 文件夹下，按目录结构创建缺少的子文件夹，parent 为空时导入到邮箱根目录
+*/
+func (m *myMailBox) ImportArchive(ctx context.Context, parent string, format ArchiveFormat, dir string) (int, error) {
+	if format != ArchiveMbox && format != ArchiveMaildir {
+		return 0, fmt.Errorf("unknown archive format: %s", format)
+	}
+	names, err := readArchiveFolders(dir)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case format == ArchiveMbox && !d.IsDir() && strings.HasSuffix(path, ".mbox"):
+			folderId, err := m.ensureFolderPath(ctx, parent, strings.TrimSuffix(filepath.ToSlash(rel), ".mbox"), names)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			n, err := m.importMbox(ctx, folderId, f)
+			count += n
+			return err
+		case format == ArchiveMaildir && d.IsDir() && rel != ".":
+			if _, err := os.Stat(filepath.Join(path, "cur")); err != nil {
+				return nil
+			}
+			folderId, err := m.ensureFolderPath(ctx, parent, filepath.ToSlash(rel), names)
+			if err != nil {
+				return err
+			}
+			n, err := m.importMaildir(ctx, folderId, path)
+			count += n
+			return err
+		}
+		return nil
+	})
+	return count, err
+}
+
+// readArchiveFolders 读取 ArchiveFoldersFile，不存在时返回空，目录名即为文件夹名称
+func readArchiveFolders(dir string) (map[string]string, error) {
+	names := map[string]string{}
+	b, err := os.ReadFile(filepath.Join(dir, ArchiveFoldersFile))
+	if os.IsNotExist(err) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &names); err != nil {
+		return nil, fmt.Errorf("decode %s failed: %v", ArchiveFoldersFile, err)
+	}
+	return names, nil
+}
+
+// ensureFolderPath 在 parent 下按 a/b/c 逐级查找或创建文件夹，names 中有对应路径时使用原显示名称，返回最后一级的 id
+func (m *myMailBox) ensureFolderPath(ctx context.Context, parent string, path string, names map[string]string) (string, error) {
+	folders, err := m.Folders(ctx)
+	if err != nil {
+		return "", err
+	}
+	current := ""
+	children := folders
+	if parent != "" {
+		if current, err = m.Folder(parent).ID(ctx); err != nil {
+			return "", err
+		}
+		found := findMailFolderById(folders, current)
+		if found == nil {
+			return "", fmt.Errorf("mail folder %s not found", parent)
+		}
+		current = found.ID
+		children = found.Children
+	}
+
+	segments := strings.Split(path, "/")
+	for i, name := range segments {
+		if original, ok := names[strings.Join(segments[:i+1], "/")]; ok {
+			name = original
+		}
+		var next *MailFolderInfo
+		for i := range children {
+			if strings.EqualFold(children[i].DisplayName, name) {
+				next = &children[i]
+				break
+			}
+		}
+		if next == nil {
+			created, err := m.CreateFolder(ctx, current, name)
+			if err != nil {
+				return "", err
+			}
+			next = created
+		}
+		current = next.ID
+		children = next.Children
+	}
+	return current, nil
+}
+
+// readMbox 按 From 分隔行拆分邮件，并还原 mboxrd 转义
+func readMbox(r io.Reader, fn func(mime []byte) error) error {
+	br := bufio.NewReader(r)
+	var (
+		buf     bytes.Buffer
+		started bool
+	)
+	flush := func() error {
+		if !started {
+			return nil
+		}
+		mime := bytes.TrimRight(buf.Bytes(), "\n")
+		buf.Reset()
+		if len(mime) == 0 {
+			return nil
+		}
+		return fn(append(mime, '\n'))
+	}
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			if strings.HasPrefix(line, "From ") {
+				if ferr := flush(); ferr != nil {
+					return ferr
+				}
+				started = true
+			} else if started {
+				if mboxFromEscaped.MatchString(line) {
+					line = line[1:]
+				}
+				buf.WriteString(line)
+			}
+		}
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
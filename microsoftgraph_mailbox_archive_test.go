@@ -0,0 +1,108 @@
+package msclient
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMboxRoundTrip(t *testing.T) {
+	received := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		mime string
+		want string
+	}{
+		{
+			name: "plain",
+			mime: "Subject: hi\r\n\r\nhello\r\n",
+			want: "Subject: hi\n\nhello\n",
+		},
+		{
+			name: "from line in body",
+			mime: "Subject: quote\n\nFrom the team\nthanks\n",
+			want: "Subject: quote\n\nFrom the team\nthanks\n",
+		},
+		{
+			name: "already escaped from line",
+			mime: "Subject: nested\n\n>From quoted\n>>From twice\n",
+			want: "Subject: nested\n\n>From quoted\n>>From twice\n",
+		},
+		{
+			name: "from without space is not escaped",
+			mime: "Subject: x\n\nFromage\n",
+			want: "Subject: x\n\nFromage\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeMbox(&buf, "bob@contoso.com", received, []byte(tt.mime)); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(buf.String(), "From bob@contoso.com Wed May  1 10:30:00 2024\n") {
+				t.Errorf("unexpected separator line: %q", strings.SplitN(buf.String(), "\n", 2)[0])
+			}
+			var got []string
+			err := readMbox(&buf, func(mime []byte) error {
+				got = append(got, string(mime))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("round trip = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMboxMultiple(t *testing.T) {
+	var buf bytes.Buffer
+	received := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mails := []string{"Subject: a\n\nfirst\n", "Subject: b\n\nFrom me\n", "Subject: c\n\nthird\n"}
+	for _, mime := range mails {
+		if err := writeMbox(&buf, "a@contoso.com", received, []byte(mime)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	if err := readMbox(&buf, func(mime []byte) error {
+		got = append(got, string(mime))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, mails) {
+		t.Errorf("readMbox() = %q, want %q", got, mails)
+	}
+}
+
+func TestArchivePaths(t *testing.T) {
+	folders := []MailFolderInfo{
+		{DisplayName: "Inbox", Children: []MailFolderInfo{
+			{DisplayName: "a/b"},
+			{DisplayName: "a_b"},
+		}},
+		{DisplayName: ".."},
+		{DisplayName: "inbox"},
+	}
+	targets, names := archivePaths(folders)
+	var paths []string
+	for _, target := range targets {
+		paths = append(paths, target.path)
+	}
+	wantPaths := []string{"Inbox", "Inbox/a_b", "Inbox/a_b_2", "__", "inbox_2"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("paths = %q, want %q", paths, wantPaths)
+	}
+	wantNames := map[string]string{
+		"Inbox": "Inbox", "Inbox/a_b": "a/b", "Inbox/a_b_2": "a_b", "__": "..", "inbox_2": "inbox",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("names = %v, want %v", names, wantNames)
+	}
+}
@@ -0,0 +1,116 @@
+package msclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	graphusers "github.com/microsoftgraph/msgraph-sdk-go/users"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type AttachmentKind string
+
+const (
+	AttachmentKindFile      AttachmentKind = "file"
+	AttachmentKindItem      AttachmentKind = "item"      // 嵌入的邮件、日程等 Outlook 项目
+	AttachmentKindReference AttachmentKind = "reference" // OneDrive/SharePoint 文件链接
+)
+
+// MailAttachment 收到邮件中的附件信息，不包含附件内容，内容使用 DownloadAttachment 读取
+type MailAttachment struct {
+	ID                   string         `json:"id"`
+	Name                 string         `json:"name"`
+	ContentType          string         `json:"contentType"`
+	Size                 int32          `json:"size"`
+	IsInline             bool           `json:"isInline"`
+	LastModifiedDateTime time.Time      `json:"lastModifiedDateTime"`
+	Kind                 AttachmentKind `json:"kind"`
+}
+
+/*
+Attachments 列出邮件的附件，只返回元数据
+https://learn.microsoft.com/en-us/graph/api/message-list-attachments?view=graph-rest-1.0
+*/
+func (m *myMailBox) Attachments(ctx context.Context, messageId string) ([]MailAttachment, error) {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return nil, fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	configuration := &graphusers.ItemMessagesItemAttachmentsRequestBuilderGetRequestConfiguration{
+		QueryParameters: &graphusers.ItemMessagesItemAttachmentsRequestBuilderGetQueryParameters{
+			Select: []string{"id", "name", "contentType", "size", "isInline", "lastModifiedDateTime"},
+		},
+	}
+	resp, err := m.user(client).Messages().ByMessageId(messageId).Attachments().Get(ctx, configuration)
+	if err != nil {
+		return nil, fmt.Errorf("list attachments failed: %v", err)
+	}
+
+	attachments := make([]MailAttachment, 0, len(resp.GetValue()))
+	for _, item := range resp.GetValue() {
+		attachments = append(attachments, newMailAttachment(item))
+	}
+	return attachments, nil
+}
+
+func newMailAttachment(item models.Attachmentable) MailAttachment {
+	a := MailAttachment{Kind: AttachmentKindFile}
+	if v := item.GetId(); v != nil {
+		a.ID = *v
+	}
+	if v := item.GetName(); v != nil {
+		a.Name = *v
+	}
+	if v := item.GetContentType(); v != nil {
+		a.ContentType = *v
+	}
+	if v := item.GetSize(); v != nil {
+		a.Size = *v
+	}
+	if v := item.GetIsInline(); v != nil {
+		a.IsInline = *v
+	}
+	if v := item.GetLastModifiedDateTime(); v != nil {
+		a.LastModifiedDateTime = *v
+	}
+	switch item.(type) {
+	case models.ItemAttachmentable:
+		a.Kind = AttachmentKindItem
+	case models.ReferenceAttachmentable:
+		a.Kind = AttachmentKindReference
+	}
+	return a
+}
+
+/*
+DownloadAttachment 将附件原始内容写入 w，文件附件为文件内容，项目附件为 MIME，不支持引用附件
+https://learn.microsoft.com/en-us/graph/api/attachment-get?view=graph-rest-1.0#example-6-get-the-raw-contents-of-a-file-attachment-on-a-message
+*/
+func (m *myMailBox) DownloadAttachment(ctx context.Context, messageId string, attachmentId string, w io.Writer) error {
+	u := fmt.Sprintf("%s/v1.0%s/messages/%s/attachments/%s/$value",
+		GraphAPIHost, m.userPath(), url.PathEscape(messageId), url.PathEscape(attachmentId))
+	return m.stream(ctx, u, w)
+}
+
+// stream GET 请求并将响应内容写入 w
+func (m *myMailBox) stream(ctx context.Context, u string, w io.Writer) error {
+	resp, err := graphDo(ctx, m.token, http.MethodGet, u, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		b, _ := io.ReadAll(resp.Body)
+		if err = checkApiError(b); err != nil {
+			return err
+		}
+		return fmt.Errorf("error status: %d , %s", resp.StatusCode, b)
+	}
+	if _, err = io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("error writing response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package msclient
+
+import (
+	"context"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// conversationProperties 分组和汇总需要的字段，指定 Properties 时自动补充
+var conversationProperties = []string{
+	"id", "conversationId", "subject", "receivedDateTime", "sentDateTime", "isRead", "from", "toRecipients", "ccRecipients",
+}
+
+// Conversation 同一 conversationId 的邮件线程
+type Conversation struct {
+	ID      string `json:"id"`
+	Subject string `json:"subject"` // 最早一封邮件的主题
+	// Participants 发件人和收件人，按首次出现的顺序，地址不区分大小写去重
+	Participants []MailAddress `json:"participants"`
+	LastActivity time.Time     `json:"lastActivity"`
+	MessageCount int           `json:"messageCount"`
+	UnreadCount  int           `json:"unreadCount"`
+	// Messages 按接收时间从早到晚排列
+	Messages []Mail `json:"messages"`
+}
+
+/*
+Conversations 读取文件夹中的邮件并按 conversationId 分组，folder 为空时读取全部邮件，
+返回的线程按最后活动时间从新到旧排列；opts.Max 限制的是读取的邮件数量
+*/
+func (m *myMailBox) Conversations(ctx context.Context, folder string, opts MessageOptions) ([]Conversation, error) {
+	properties := opts.Properties
+	if len(properties) == 0 {
+		properties = m.mailProperties
+	}
+	if len(properties) > 0 {
+		opts.Properties = mergeProperties(properties, conversationProperties)
+	}
+
+	var pager *Pager[models.Messageable]
+	if folder == "" {
+		pager = m.Messages(ctx, opts)
+	} else {
+		pager = m.Folder(folder).Messages(ctx, opts)
+	}
+	messages, err := pager.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return groupConversations(Mails(messages).Mail()), nil
+}
+
+func groupConversations(mails []Mail) []Conversation {
+	var (
+		conversations []*Conversation
+		byId          = map[string]*Conversation{}
+	)
+	for _, mail := range mails {
+		id := mail.ConversationID
+		if id == "" {
+			id = mail.ID
+		}
+		c, ok := byId[id]
+		if !ok {
+			c = &Conversation{ID: id}
+			byId[id] = c
+			conversations = append(conversations, c)
+		}
+		c.Messages = append(c.Messages, mail)
+	}
+
+	result := make([]Conversation, 0, len(conversations))
+	for _, c := range conversations {
+		sort.SliceStable(c.Messages, func(i, j int) bool {
+			return mailTime(c.Messages[i]).Before(mailTime(c.Messages[j]))
+		})
+		seen := map[string]bool{}
+		addParticipant := func(address MailAddress) {
+			key := strings.ToLower(address.Address)
+			if key == "" || seen[key] {
+				return
+			}
+			seen[key] = true
+			c.Participants = append(c.Participants, address)
+		}
+		for _, mail := range c.Messages {
+			addParticipant(mail.From)
+			for _, address := range mail.To {
+				addParticipant(address)
+			}
+			for _, address := range mail.Cc {
+				addParticipant(address)
+			}
+			if !mail.IsRead {
+				c.UnreadCount++
+			}
+			if t := mailTime(mail); t.After(c.LastActivity) {
+				c.LastActivity = t
+			}
+		}
+		c.MessageCount = len(c.Messages)
+		c.Subject = c.Messages[0].Subject
+		result = append(result, *c)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastActivity.After(result[j].LastActivity)
+	})
+	return result
+}
+
+// mailTime 接收时间，草稿等没有接收时间的邮件使用发送时间
+func mailTime(mail Mail) time.Time {
+	if !mail.ReceivedDateTime.IsZero() {
+		return mail.ReceivedDateTime
+	}
+	return mail.SentDateTime
+}
+
+// mergeProperties 在 properties 后追加缺少的 required 字段
+func mergeProperties(properties []string, required []string) []string {
+	merged := append([]string{}, properties...)
+	for _, p := range required {
+		found := false
+		for _, existing := range merged {
+			if strings.EqualFold(existing, p) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
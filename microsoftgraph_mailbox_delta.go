@@ -0,0 +1,202 @@
+package msclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+/*
+MessageDelta 一次增量同步的结果，DeltaToken 用于下一次同步；
+graph 不区分新增和修改，移入文件夹的旧邮件和新邮件一样出现在 Changed 中，需要区分时由调用方按已处理的 id 判断
+*/
+type MessageDelta struct {
+	Changed    []models.Messageable
+	Removed    []string
+	DeltaToken string
+}
+
+// deltaState DeltaToken 的内容
+type deltaState struct {
+	Link string `json:"link"`
+}
+
+/*
+Delta 增量读取文件夹中的邮件变化，deltaToken 为空时为首次同步，返回文件夹中的全部邮件
+https://learn.microsoft.com/en-us/graph/api/message-delta?view=graph-rest-1.0
+*/
+func (f *mailFolder) Delta(ctx context.Context, deltaToken string) (*MessageDelta, error) {
+	var (
+		state deltaState
+		u     string
+	)
+	if deltaToken != "" {
+		b, err := base64.RawURLEncoding.DecodeString(deltaToken)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delta token: %v", err)
+		}
+		if err = json.Unmarshal(b, &state); err != nil || state.Link == "" {
+			return nil, fmt.Errorf("invalid delta token: %v", err)
+		}
+		u = state.Link
+	} else {
+		id, err := f.ID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		u = fmt.Sprintf("%s/v1.0%s/mailFolders/%s/messages/delta?%s", GraphAPIHost, f.box.userPath(), url.PathEscape(id),
+			odataQuery([][2]string{{"$select", strings.Join(defaultMailProperties, ",")}}))
+	}
+
+	client, err := microsoftGraphClient(ctx, f.box.token)
+	if err != nil {
+		return nil, fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	delta := f.box.user(client).MailFolders().ByMailFolderId("delta").Messages().Delta()
+
+	result := &MessageDelta{}
+	for u != "" {
+		page, err := delta.WithUrl(u).GetAsDeltaGetResponse(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("mail delta failed: %v", err)
+		}
+		for _, message := range page.GetValue() {
+			if message == nil || message.GetId() == nil {
+				continue
+			}
+			if _, removed := message.GetAdditionalData()["@removed"]; removed {
+				result.Removed = append(result.Removed, *message.GetId())
+				continue
+			}
+			result.Changed = append(result.Changed, message)
+		}
+
+		u = ""
+		if next := page.GetOdataNextLink(); next != nil {
+			u = *next
+		} else if link := page.GetOdataDeltaLink(); link != nil {
+			b, err := json.Marshal(deltaState{Link: *link})
+			if err != nil {
+				return nil, err
+			}
+			result.DeltaToken = base64.RawURLEncoding.EncodeToString(b)
+		}
+	}
+	return result, nil
+}
+
+/*
+Sync 从 store 读取上次的 DeltaToken 进行增量同步，读取成功后立即保存新的 DeltaToken；
+需要处理完邮件再保存 DeltaToken 时使用 Delta 和 DeltaKey 自行保存
+*/
+func (f *mailFolder) Sync(ctx context.Context, store DeltaTokenStore) (*MessageDelta, error) {
+	key := f.DeltaKey()
+	token, err := store.Load(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("load delta token failed: %v", err)
+	}
+	delta, err := f.Delta(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err = store.Save(ctx, key, delta.DeltaToken); err != nil {
+		return nil, fmt.Errorf("save delta token failed: %v", err)
+	}
+	return delta, nil
+}
+
+func (f *mailFolder) DeltaKey() string {
+	return f.box.userPath() + "/mailFolders/" + f.name
+}
+
+// DeltaTokenStore 保存各文件夹的 DeltaToken，Load 在 key 不存在时返回空字符串
+type DeltaTokenStore interface {
+	Load(ctx context.Context, key string) (string, error)
+	Save(ctx context.Context, key string, token string) error
+}
+
+// MemoryDeltaTokenStore 进程内保存 DeltaToken，重启后重新全量同步
+type MemoryDeltaTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func NewMemoryDeltaTokenStore() *MemoryDeltaTokenStore {
+	return &MemoryDeltaTokenStore{tokens: map[string]string{}}
+}
+
+func (s *MemoryDeltaTokenStore) Load(_ context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[key], nil
+}
+
+func (s *MemoryDeltaTokenStore) Save(_ context.Context, key string, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[key] = token
+	return nil
+}
+
+// FileDeltaTokenStore 以 json 文件保存 DeltaToken
+type FileDeltaTokenStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileDeltaTokenStore(path string) *FileDeltaTokenStore {
+	return &FileDeltaTokenStore{path: path}
+}
+
+func (s *FileDeltaTokenStore) Load(_ context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	return tokens[key], nil
+}
+
+func (s *FileDeltaTokenStore) Save(_ context.Context, key string, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	tokens[key] = token
+	b, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *FileDeltaTokenStore) read() (map[string]string, error) {
+	tokens := map[string]string{}
+	b, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return tokens, nil
+	}
+	if err = json.Unmarshal(b, &tokens); err != nil {
+		return nil, fmt.Errorf("decode delta token file failed: %v", err)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,142 @@
+package msclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MaxInlineAttachmentSize 超过该大小的附件需要使用上传会话
+const MaxInlineAttachmentSize = 3 * 1024 * 1024
+
+/*
+CreateDraft 在草稿箱创建邮件，返回草稿 id，可以在 Outlook 中审阅后再发送
+https://learn.microsoft.com/en-us/graph/api/user-post-messages?view=graph-rest-1.0
+*/
+func (m *myMailBox) CreateDraft(ctx context.Context, msg Message) (string, error) {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return "", fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	draft, err := m.user(client).Messages().Post(ctx, msg.messageable(), nil)
+	if err != nil {
+		return "", fmt.Errorf("create draft failed: %v", err)
+	}
+	if draft.GetId() == nil {
+		return "", fmt.Errorf("draft id not found")
+	}
+	return *draft.GetId(), nil
+}
+
+/*
+UpdateDraft 更新草稿中已设置的字段，msg.Attachments 会追加到草稿而不是替换已有附件
+https://learn.microsoft.com/en-us/graph/api/message-update?view=graph-rest-1.0
+*/
+func (m *myMailBox) UpdateDraft(ctx context.Context, draftId string, msg Message) error {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	attachments := msg.Attachments
+	msg.Attachments = nil
+	if _, err = m.user(client).Messages().ByMessageId(draftId).Patch(ctx, msg.messageable(), nil); err != nil {
+		return fmt.Errorf("update draft failed: %v", err)
+	}
+	for _, attachment := range attachments {
+		if _, err = m.AddAttachment(ctx, draftId, attachment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+AddAttachment 给草稿添加附件，返回附件 id，超过 3 MB 的附件需要使用上传会话
+https://learn.microsoft.com/en-us/graph/api/message-post-attachments?view=graph-rest-1.0
+*/
+func (m *myMailBox) AddAttachment(ctx context.Context, draftId string, attachment Attachment) (string, error) {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return "", fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	created, err := m.user(client).Messages().ByMessageId(draftId).Attachments().Post(ctx, attachment.attachmentable(), nil)
+	if err != nil {
+		return "", fmt.Errorf("add attachment failed: %v", err)
+	}
+	if created.GetId() == nil {
+		return "", nil
+	}
+	return *created.GetId(), nil
+}
+
+/*
+SendDraft 发送草稿
+https://learn.microsoft.com/en-us/graph/api/message-send?view=graph-rest-1.0
+*/
+func (m *myMailBox) SendDraft(ctx context.Context, draftId string) error {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	if err = m.user(client).Messages().ByMessageId(draftId).Send().Post(ctx, nil); err != nil {
+		return fmt.Errorf("send draft failed: %v", err)
+	}
+	return nil
+}
+
+/*
+AddLargeAttachment 通过上传会话分片上传附件到草稿，返回附件 id，最大 150 MB，小于 3 MB 时直接使用 AddAttachment
+https://learn.microsoft.com/en-us/graph/outlook-large-attachments
+*/
+func (m *myMailBox) AddLargeAttachment(ctx context.Context, draftId string, name string, r io.Reader, size int64) (string, error) {
+	contentType := Ext2Mime[strings.ToLower(RegexGet(name, `(\.[^\.]+)$`))]
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if size < MaxInlineAttachmentSize {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return "", fmt.Errorf("read attachment failed: %v", err)
+		}
+		return m.AddAttachment(ctx, draftId, Attachment{Name: name, ContentType: contentType, Content: content})
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"AttachmentItem": map[string]any{
+			"attachmentType": "file",
+			"name":           name,
+			"size":           size,
+			"contentType":    contentType,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	u := fmt.Sprintf("%s/v1.0%s/messages/%s/attachments/createUploadSession", GraphAPIHost, m.userPath(), url.PathEscape(draftId))
+	body, err := graphRequest(ctx, m.token, http.MethodPost, u, bytes.NewReader(payload), map[string][]string{
+		"Content-Type": {"application/json"},
+	})
+	if err != nil {
+		return "", err
+	}
+	if err = checkApiError(body); err != nil {
+		return "", err
+	}
+	uploadUrl := gjson.GetBytes(body, "uploadUrl")
+	if !uploadUrl.Exists() {
+		return "", fmt.Errorf("the uploadUrl not found in attachment upload session")
+	}
+
+	f := &bigFile{ctx: ctx, uploadUrl: uploadUrl.String(), fileSize: size}
+	if err = f.copyFrom(r); err != nil {
+		return "", fmt.Errorf("upload attachment failed: %v", err)
+	}
+	// 上传完成后 Location 形如 .../messages('{id}')/attachments('{attachmentId}')
+	return RegexGet(f.location, `[Aa]ttachments\('([^']+)'\)`), nil
+}
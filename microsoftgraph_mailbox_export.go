@@ -0,0 +1,177 @@
+package msclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ExportStateFile ExportFolder 在导出目录中记录已导出邮件 id 的文件，中断后再次导出会跳过这些邮件
+const ExportStateFile = ".export-state"
+
+/*
+ExportMIME 将邮件原始 MIME 内容（RFC 822）写入 w
+https://learn.microsoft.com/en-us/graph/outlook-get-mime-message
+*/
+func (m *myMailBox) ExportMIME(ctx context.Context, messageId string, w io.Writer) error {
+	u := fmt.Sprintf("%s/v1.0%s/messages/%s/$value", GraphAPIHost, m.userPath(), url.PathEscape(messageId))
+	return m.stream(ctx, u, w)
+}
+
+/*
+ExportFolder 将文件夹中的邮件导出为 .eml 文件，文件名为接收时间和主题，返回本次导出的数量
+*/
+func (m *myMailBox) ExportFolder(ctx context.Context, folder string, dir string) (int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	state, err := openExportState(filepath.Join(dir, ExportStateFile))
+	if err != nil {
+		return 0, err
+	}
+	defer state.Close()
+
+	var count int
+	pager := m.Folder(folder).Messages(ctx, MessageOptions{
+		PageSize:   100,
+		Properties: []string{"id", "subject", "receivedDateTime"},
+	})
+	for pager.HasNext() {
+		messages, err := pager.Next(ctx)
+		if err != nil {
+			return count, err
+		}
+		for _, message := range messages {
+			id := message.GetId()
+			if id == nil || state.done[*id] {
+				continue
+			}
+			var (
+				subject  string
+				received time.Time
+			)
+			if v := message.GetSubject(); v != nil {
+				subject = *v
+			}
+			if v := message.GetReceivedDateTime(); v != nil {
+				received = *v
+			}
+			path, err := emlPath(dir, received, subject)
+			if err != nil {
+				return count, err
+			}
+			if err = m.exportEml(ctx, *id, path); err != nil {
+				return count, err
+			}
+			if err = state.add(*id); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// exportEml 先写临时文件，完成后再重命名，避免中断时留下不完整的 .eml
+func (m *myMailBox) exportEml(ctx context.Context, messageId string, path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = m.ExportMIME(ctx, messageId, f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("export mail %s failed: %v", messageId, err)
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// emlPath 生成不重复的文件名，如 20240501T103000Z_Invoice 42.eml
+func emlPath(dir string, received time.Time, subject string) (string, error) {
+	base := received.UTC().Format("20060102T150405Z") + "_" + safeFileName(subject)
+	path := filepath.Join(dir, base+".eml")
+	for i := 2; ; i++ {
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s_%d.eml", base, i))
+	}
+}
+
+// maxFileNameBytes safeFileName 的最大字节数，文件系统一般限制为 255 字节，留出时间前缀和序号后缀的长度
+const maxFileNameBytes = 200
+
+// safeFileName 替换文件名中不允许的字符，按字节限制长度，不会截断多字节字符
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if len(name) > maxFileNameBytes {
+		cut := maxFileNameBytes
+		for cut > 0 && !utf8.RuneStart(name[cut]) {
+			cut--
+		}
+		name = name[:cut]
+	}
+	if name == "" {
+		name = "no-subject"
+	}
+	return name
+}
+
+// exportState 已导出邮件 id，每行一个，追加写入
+type exportState struct {
+	file *os.File
+	done map[string]bool
+}
+
+func openExportState(path string) (*exportState, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open export state failed: %v", err)
+	}
+	state := &exportState{file: f, done: map[string]bool{}}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if id := strings.TrimSpace(scanner.Text()); id != "" {
+			state.done[id] = true
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read export state failed: %v", err)
+	}
+	return state, nil
+}
+
+func (s *exportState) add(id string) error {
+	if _, err := s.file.WriteString(id + "\n"); err != nil {
+		return fmt.Errorf("write export state failed: %v", err)
+	}
+	s.done[id] = true
+	return nil
+}
+
+func (s *exportState) Close() error {
+	return s.file.Close()
+}
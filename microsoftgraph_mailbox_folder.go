@@ -0,0 +1,297 @@
+package msclient
+
+import (
+	"context"
+	"fmt"
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// 常用的邮件文件夹 well-known name，可以直接当作文件夹 id 使用
+// https://learn.microsoft.com/en-us/graph/api/resources/mailfolder?view=graph-rest-1.0
+const (
+	MailFolderInbox        = "inbox"
+	MailFolderSentItems    = "sentitems"
+	MailFolderDrafts       = "drafts"
+	MailFolderDeletedItems = "deleteditems"
+	MailFolderJunkEmail    = "junkemail"
+	MailFolderArchive      = "archive"
+	MailFolderOutbox       = "outbox"
+)
+
+var wellKnownMailFolders = []string{
+	MailFolderInbox, MailFolderSentItems, MailFolderDrafts, MailFolderDeletedItems,
+	MailFolderJunkEmail, MailFolderArchive, MailFolderOutbox,
+}
+
+const mailFolderPageSize = 100
+
+type MailFolderInfo struct {
+	ID               string           `json:"id"`
+	DisplayName      string           `json:"displayName"`
+	ParentFolderID   string           `json:"parentFolderId"`
+	WellKnownName    string           `json:"wellKnownName,omitempty"`
+	Path             string           `json:"path"` // 以 / 分隔的显示名称路径，如 Inbox/Support
+	ChildFolderCount int32            `json:"childFolderCount"`
+	UnreadItemCount  int32            `json:"unreadItemCount"`
+	TotalItemCount   int32            `json:"totalItemCount"`
+	IsHidden         bool             `json:"isHidden"`
+	Children         []MailFolderInfo `json:"children,omitempty"`
+}
+
+// MailFolder 邮件文件夹，按 well-known name、id 或显示名称路径定位，首次使用时解析 id
+type MailFolder interface {
+	ID(ctx context.Context) (string, error)
+	Messages(ctx context.Context, opts MessageOptions) *Pager[models.Messageable]
+	Delta(ctx context.Context, deltaToken string) (*MessageDelta, error)
+	Sync(ctx context.Context, store DeltaTokenStore) (*MessageDelta, error)
+	// DeltaKey Sync 在 DeltaTokenStore 中保存 DeltaToken 使用的 key
+	DeltaKey() string
+}
+
+/*
+Folders 返回邮件文件夹树，包含子文件夹，并标记 inbox、sentitems、archive 等 well-known name
+https://learn.microsoft.com/en-us/graph/api/user-list-mailfolders?view=graph-rest-1.0
+*/
+func (m *myMailBox) Folders(ctx context.Context) ([]MailFolderInfo, error) {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return nil, fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+
+	wellKnown := map[string]string{}
+	for _, name := range wellKnownMailFolders {
+		folder, err := m.user(client).MailFolders().ByMailFolderId(name).Get(ctx, nil)
+		if err != nil || folder.GetId() == nil {
+			// archive 等文件夹不一定存在
+			continue
+		}
+		wellKnown[*folder.GetId()] = name
+	}
+
+	u := fmt.Sprintf("%s/v1.0%s/mailFolders?$top=%d", GraphAPIHost, m.userPath(), mailFolderPageSize)
+	return m.folderTree(ctx, client, u, "", wellKnown)
+}
+
+func (m *myMailBox) folderTree(ctx context.Context, client *msgraphsdk.GraphServiceClient, u string, parentPath string, wellKnown map[string]string) ([]MailFolderInfo, error) {
+	var folders []MailFolderInfo
+	for u != "" {
+		page, err := m.user(client).MailFolders().WithUrl(u).Get(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("list mail folders failed: %v", err)
+		}
+		for _, item := range page.GetValue() {
+			folder := newMailFolderInfo(item)
+			folder.WellKnownName = wellKnown[folder.ID]
+			folder.Path = strings.TrimPrefix(parentPath+"/"+folder.DisplayName, "/")
+			if folder.ChildFolderCount > 0 {
+				childUrl := fmt.Sprintf("%s/v1.0%s/mailFolders/%s/childFolders?$top=%d",
+					GraphAPIHost, m.userPath(), url.PathEscape(folder.ID), mailFolderPageSize)
+				folder.Children, err = m.folderTree(ctx, client, childUrl, folder.Path, wellKnown)
+				if err != nil {
+					return nil, err
+				}
+			}
+			folders = append(folders, folder)
+		}
+		u = ""
+		if next := page.GetOdataNextLink(); next != nil {
+			u = *next
+		}
+	}
+	return folders, nil
+}
+
+func newMailFolderInfo(item models.MailFolderable) MailFolderInfo {
+	folder := MailFolderInfo{}
+	if v := item.GetId(); v != nil {
+		folder.ID = *v
+	}
+	if v := item.GetDisplayName(); v != nil {
+		folder.DisplayName = *v
+	}
+	if v := item.GetParentFolderId(); v != nil {
+		folder.ParentFolderID = *v
+	}
+	if v := item.GetChildFolderCount(); v != nil {
+		folder.ChildFolderCount = *v
+	}
+	if v := item.GetUnreadItemCount(); v != nil {
+		folder.UnreadItemCount = *v
+	}
+	if v := item.GetTotalItemCount(); v != nil {
+		folder.TotalItemCount = *v
+	}
+	if v := item.GetIsHidden(); v != nil {
+		folder.IsHidden = *v
+	}
+	return folder
+}
+
+func (m *myMailBox) Folder(nameOrId string) MailFolder {
+	return &mailFolder{box: m, name: nameOrId}
+}
+
+/*
+CreateFolder 创建文件夹，parent 为空时创建在根目录
+https://learn.microsoft.com/en-us/graph/api/user-post-mailfolders?view=graph-rest-1.0
+*/
+func (m *myMailBox) CreateFolder(ctx context.Context, parent string, name string) (*MailFolderInfo, error) {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return nil, fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	body := models.NewMailFolder()
+	body.SetDisplayName(&name)
+
+	var created models.MailFolderable
+	if parent == "" {
+		created, err = m.user(client).MailFolders().Post(ctx, body, nil)
+	} else {
+		var parentId string
+		if parentId, err = m.Folder(parent).ID(ctx); err != nil {
+			return nil, err
+		}
+		created, err = m.user(client).MailFolders().ByMailFolderId(parentId).ChildFolders().Post(ctx, body, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create mail folder failed: %v", err)
+	}
+	folder := newMailFolderInfo(created)
+	return &folder, nil
+}
+
+func (m *myMailBox) RenameFolder(ctx context.Context, folder string, name string) error {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	id, err := m.Folder(folder).ID(ctx)
+	if err != nil {
+		return err
+	}
+	body := models.NewMailFolder()
+	body.SetDisplayName(&name)
+	if _, err = m.user(client).MailFolders().ByMailFolderId(id).Patch(ctx, body, nil); err != nil {
+		return fmt.Errorf("rename mail folder failed: %v", err)
+	}
+	// 子文件夹的路径随之变化，清空全部缓存
+	m.resetFolderIds()
+	return nil
+}
+
+func (m *myMailBox) DeleteFolder(ctx context.Context, folder string) error {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	id, err := m.Folder(folder).ID(ctx)
+	if err != nil {
+		return err
+	}
+	if err = m.user(client).MailFolders().ByMailFolderId(id).Delete(ctx, nil); err != nil {
+		return fmt.Errorf("delete mail folder failed: %v", err)
+	}
+	// 子文件夹的路径随之变化，清空全部缓存
+	m.resetFolderIds()
+	return nil
+}
+
+type mailFolder struct {
+	box  *myMailBox
+	name string
+
+	mu sync.Mutex
+	id string
+}
+
+// ID well-known name 直接使用；其他先按 id 查询，查不到再在文件夹树中按显示名称或路径（如 Inbox/Support）查找，
+// 结果缓存在邮箱中，同一邮箱再次使用同名文件夹时不再查询
+func (f *mailFolder) ID(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.id != "" {
+		return f.id, nil
+	}
+	if f.name == "" {
+		return "", fmt.Errorf("missing mail folder name")
+	}
+	for _, name := range wellKnownMailFolders {
+		if strings.EqualFold(f.name, name) {
+			f.id = name
+			return f.id, nil
+		}
+	}
+	if id, ok := f.box.folderIds.Load(f.name); ok {
+		f.id = id.(string)
+		return f.id, nil
+	}
+	id, err := f.lookup(ctx)
+	if err != nil {
+		return "", err
+	}
+	f.id = id
+	f.box.folderIds.Store(f.name, id)
+	return f.id, nil
+}
+
+func (f *mailFolder) lookup(ctx context.Context) (string, error) {
+	client, err := microsoftGraphClient(ctx, f.box.token)
+	if err != nil {
+		return "", fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	if folder, err := f.box.user(client).MailFolders().ByMailFolderId(f.name).Get(ctx, nil); err == nil && folder.GetId() != nil {
+		return *folder.GetId(), nil
+	}
+
+	folders, err := f.box.Folders(ctx)
+	if err != nil {
+		return "", err
+	}
+	if folder := findMailFolder(folders, f.name); folder != nil {
+		return folder.ID, nil
+	}
+	return "", fmt.Errorf("mail folder %s not found", f.name)
+}
+
+func (m *myMailBox) resetFolderIds() {
+	m.folderIds.Range(func(key, _ any) bool {
+		m.folderIds.Delete(key)
+		return true
+	})
+}
+
+// findMailFolder 按路径或显示名称查找，忽略大小写，路径优先
+func findMailFolder(folders []MailFolderInfo, name string) *MailFolderInfo {
+	var byName *MailFolderInfo
+	var walk func(folders []MailFolderInfo) *MailFolderInfo
+	walk = func(folders []MailFolderInfo) *MailFolderInfo {
+		for i := range folders {
+			if strings.EqualFold(folders[i].Path, name) {
+				return &folders[i]
+			}
+			if byName == nil && strings.EqualFold(folders[i].DisplayName, name) {
+				byName = &folders[i]
+			}
+			if found := walk(folders[i].Children); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	if found := walk(folders); found != nil {
+		return found
+	}
+	return byName
+}
+
+/*
+Messages 分页读取文件夹中的邮件
+https://learn.microsoft.com/en-us/graph/api/mailfolder-list-messages?view=graph-rest-1.0
+*/
+func (f *mailFolder) Messages(ctx context.Context, opts MessageOptions) *Pager[models.Messageable] {
+	return f.box.messages(f.ID, opts)
+}
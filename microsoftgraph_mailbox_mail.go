@@ -0,0 +1,170 @@
+package msclient
+
+import (
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	htmlInvisible = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlBreak     = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|tr|li|h[1-6])>`)
+	htmlTag       = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLines    = regexp.MustCompile(`\n[ \t]*\n[ \t\n]*`)
+)
+
+// MailAddress 邮件地址
+type MailAddress struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+}
+
+// MailHeader 邮件头，同名的头可能出现多次
+type MailHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+/*
+Mail 收到的邮件，由 sdk 的 Message 转换而来，未请求的字段为零值；
+Headers 需要在 Properties 或 Hydrate 中加入 internetMessageHeaders，Attachments 需要 ExpandAttachments
+*/
+type Mail struct {
+	ID                string           `json:"id"`
+	Subject           string           `json:"subject"`
+	From              MailAddress      `json:"from"`
+	To                []MailAddress    `json:"to,omitempty"`
+	Cc                []MailAddress    `json:"cc,omitempty"`
+	ReceivedDateTime  time.Time        `json:"receivedDateTime"`
+	SentDateTime      time.Time        `json:"sentDateTime"`
+	BodyText          string           `json:"bodyText,omitempty"` // HTML 邮件为去除标签后的文本
+	BodyHTML          string           `json:"bodyHtml,omitempty"` // 纯文本邮件为空
+	Importance        string           `json:"importance,omitempty"`
+	ConversationID    string           `json:"conversationId,omitempty"`
+	InternetMessageID string           `json:"internetMessageId,omitempty"`
+	IsRead            bool             `json:"isRead"`
+	Categories        []string         `json:"categories,omitempty"`
+	Headers           []MailHeader     `json:"headers,omitempty"`
+	Attachments       []MailAttachment `json:"attachments,omitempty"`
+
+	// Raw sdk 原始对象，用于读取 Mail 中没有的字段
+	Raw models.Messageable `json:"-"`
+}
+
+// NewMail 将 sdk 的 Message 转换为 Mail
+func NewMail(message models.Messageable) Mail {
+	mail := Mail{Raw: message}
+	if message == nil {
+		return mail
+	}
+	if v := message.GetId(); v != nil {
+		mail.ID = *v
+	}
+	if v := message.GetSubject(); v != nil {
+		mail.Subject = *v
+	}
+	if v := message.GetFrom(); v != nil {
+		mail.From = newMailAddress(v)
+	}
+	mail.To = newMailAddresses(message.GetToRecipients())
+	mail.Cc = newMailAddresses(message.GetCcRecipients())
+	if v := message.GetReceivedDateTime(); v != nil {
+		mail.ReceivedDateTime = *v
+	}
+	if v := message.GetSentDateTime(); v != nil {
+		mail.SentDateTime = *v
+	}
+	if body := message.GetBody(); body != nil && body.GetContent() != nil {
+		if t := body.GetContentType(); t != nil && *t == models.HTML_BODYTYPE {
+			mail.BodyHTML = *body.GetContent()
+			mail.BodyText = htmlText(mail.BodyHTML)
+		} else {
+			mail.BodyText = *body.GetContent()
+		}
+	}
+	if v := message.GetImportance(); v != nil {
+		mail.Importance = v.String()
+	}
+	if v := message.GetConversationId(); v != nil {
+		mail.ConversationID = *v
+	}
+	if v := message.GetInternetMessageId(); v != nil {
+		mail.InternetMessageID = *v
+	}
+	if v := message.GetIsRead(); v != nil {
+		mail.IsRead = *v
+	}
+	mail.Categories = message.GetCategories()
+	for _, h := range message.GetInternetMessageHeaders() {
+		header := MailHeader{}
+		if v := h.GetName(); v != nil {
+			header.Name = *v
+		}
+		if v := h.GetValue(); v != nil {
+			header.Value = *v
+		}
+		mail.Headers = append(mail.Headers, header)
+	}
+	for _, a := range message.GetAttachments() {
+		mail.Attachments = append(mail.Attachments, newMailAttachment(a))
+	}
+	return mail
+}
+
+// Header 返回第一个同名邮件头的值，名称不区分大小写
+func (mail Mail) Header(name string) string {
+	for _, h := range mail.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// Mail 转换为 Mail 列表
+func (ms Mails) Mail() []Mail {
+	mails := make([]Mail, 0, len(ms))
+	for _, message := range ms {
+		mails = append(mails, NewMail(message))
+	}
+	return mails
+}
+
+func newMailAddress(recipient models.Recipientable) MailAddress {
+	address := MailAddress{}
+	if recipient == nil || recipient.GetEmailAddress() == nil {
+		return address
+	}
+	if v := recipient.GetEmailAddress().GetName(); v != nil {
+		address.Name = *v
+	}
+	if v := recipient.GetEmailAddress().GetAddress(); v != nil {
+		address.Address = *v
+	}
+	return address
+}
+
+func newMailAddresses(recipients []models.Recipientable) []MailAddress {
+	if len(recipients) == 0 {
+		return nil
+	}
+	addresses := make([]MailAddress, 0, len(recipients))
+	for _, recipient := range recipients {
+		addresses = append(addresses, newMailAddress(recipient))
+	}
+	return addresses
+}
+
+// htmlText 简单去除 HTML 标签，保留换行
+func htmlText(s string) string {
+	s = htmlInvisible.ReplaceAllString(s, "")
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = blankLines.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,145 @@
+package msclient
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MailQuery 邮件查询条件，生成 $filter、$search、$orderby 参数
+//
+//	q := NewMailQuery().ReceivedAfter(t).From("bob@contoso.com").IsRead(false).OrderBy("receivedDateTime", true)
+//
+// https://learn.microsoft.com/en-us/graph/query-parameters
+type MailQuery struct {
+	filters []mailFilter
+	search  string
+	orderBy []mailOrder
+}
+
+type mailFilter struct {
+	field string
+	expr  string
+}
+
+type mailOrder struct {
+	field string
+	desc  bool
+}
+
+func NewMailQuery() *MailQuery {
+	return &MailQuery{}
+}
+
+func (q *MailQuery) ReceivedAfter(t time.Time) *MailQuery {
+	return q.where("receivedDateTime", "receivedDateTime ge %s", t.UTC().Format(time.RFC3339))
+}
+
+func (q *MailQuery) ReceivedBefore(t time.Time) *MailQuery {
+	return q.where("receivedDateTime", "receivedDateTime lt %s", t.UTC().Format(time.RFC3339))
+}
+
+func (q *MailQuery) From(address string) *MailQuery {
+	return q.where("from/emailAddress/address", "from/emailAddress/address eq '%s'", odataString(address))
+}
+
+func (q *MailQuery) IsRead(read bool) *MailQuery {
+	return q.where("isRead", "isRead eq %t", read)
+}
+
+func (q *MailQuery) HasAttachments(has bool) *MailQuery {
+	return q.where("hasAttachments", "hasAttachments eq %t", has)
+}
+
+// Category 包含指定分类，多次调用为且的关系
+func (q *MailQuery) Category(name string) *MailQuery {
+	return q.where("categories", "categories/any(c:c eq '%s')", odataString(name))
+}
+
+// Search KQL 查询，如 subject:invoice AND from:bob，不能与 filter、orderBy 同时使用
+func (q *MailQuery) Search(kql string) *MailQuery {
+	q.search = kql
+	return q
+}
+
+// OrderBy 排序字段，如 receivedDateTime
+func (q *MailQuery) OrderBy(field string, desc bool) *MailQuery {
+	q.orderBy = append(q.orderBy, mailOrder{field: field, desc: desc})
+	return q
+}
+
+func (q *MailQuery) where(field string, format string, args ...any) *MailQuery {
+	q.filters = append(q.filters, mailFilter{field: field, expr: fmt.Sprintf(format, args...)})
+	return q
+}
+
+// Filter 生成 $filter，graph 要求 $orderby 的字段按顺序出现在 $filter 的最前面，缺少时补充恒真条件
+func (q *MailQuery) Filter() string {
+	if len(q.filters) == 0 {
+		return ""
+	}
+	var (
+		exprs []string
+		used  = make([]bool, len(q.filters))
+	)
+	for _, order := range q.orderBy {
+		found := false
+		for i, f := range q.filters {
+			if !used[i] && f.field == order.field {
+				exprs = append(exprs, f.expr)
+				used[i] = true
+				found = true
+			}
+		}
+		if !found {
+			exprs = append(exprs, alwaysTrueFilter(order.field))
+		}
+	}
+	for i, f := range q.filters {
+		if !used[i] {
+			exprs = append(exprs, f.expr)
+		}
+	}
+	return strings.Join(exprs, " and ")
+}
+
+func alwaysTrueFilter(field string) string {
+	if strings.HasSuffix(field, "DateTime") {
+		return field + " ge 1900-01-01T00:00:00Z"
+	}
+	return field + " ne null"
+}
+
+func (q *MailQuery) params() ([][2]string, error) {
+	if q == nil {
+		return nil, nil
+	}
+	if q.search != "" && (len(q.filters) > 0 || len(q.orderBy) > 0) {
+		return nil, fmt.Errorf("mail query: search can not be combined with filter or order by")
+	}
+
+	var params [][2]string
+	if q.search != "" {
+		params = append(params, [2]string{"$search", `"` + strings.ReplaceAll(q.search, `"`, `\"`) + `"`})
+	}
+	if filter := q.Filter(); filter != "" {
+		params = append(params, [2]string{"$filter", filter})
+	}
+	if len(q.orderBy) > 0 {
+		orders := make([]string, 0, len(q.orderBy))
+		for _, order := range q.orderBy {
+			if order.desc {
+				orders = append(orders, order.field+" desc")
+			} else {
+				orders = append(orders, order.field+" asc")
+			}
+		}
+		params = append(params, [2]string{"$orderby", strings.Join(orders, ",")})
+	}
+	return params, nil
+}
+
+// odataString 转义 odata 字符串中的单引号
+func odataString(s string) string {
+	return strings.ReplaceAll(s, `'`, `''`)
+}
@@ -0,0 +1,103 @@
+package msclient
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMailQueryFilter(t *testing.T) {
+	after := time.Date(2024, 5, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	tests := []struct {
+		name  string
+		query *MailQuery
+		want  string
+	}{
+		{
+			name:  "empty",
+			query: NewMailQuery(),
+			want:  "",
+		},
+		{
+			name:  "single",
+			query: NewMailQuery().IsRead(false),
+			want:  "isRead eq false",
+		},
+		{
+			name:  "utc time and quote escaping",
+			query: NewMailQuery().ReceivedAfter(after).From("o'brien@contoso.com"),
+			want:  "receivedDateTime ge 2024-05-01T00:00:00Z and from/emailAddress/address eq 'o''brien@contoso.com'",
+		},
+		{
+			name:  "order by field moved first",
+			query: NewMailQuery().IsRead(false).ReceivedAfter(after).OrderBy("receivedDateTime", true),
+			want:  "receivedDateTime ge 2024-05-01T00:00:00Z and isRead eq false",
+		},
+		{
+			name:  "missing order by field gets always true filter",
+			query: NewMailQuery().HasAttachments(true).OrderBy("receivedDateTime", true).OrderBy("subject", false),
+			want:  "receivedDateTime ge 1900-01-01T00:00:00Z and subject ne null and hasAttachments eq true",
+		},
+		{
+			name:  "order by fields keep their order",
+			query: NewMailQuery().IsRead(true).Category("Invoice").OrderBy("categories", false).OrderBy("isRead", false),
+			want:  "categories/any(c:c eq 'Invoice') and isRead eq true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.Filter(); got != tt.want {
+				t.Errorf("Filter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMailQueryParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   *MailQuery
+		want    [][2]string
+		wantErr bool
+	}{
+		{
+			name:  "nil",
+			query: nil,
+			want:  nil,
+		},
+		{
+			name:  "search",
+			query: NewMailQuery().Search(`subject:"q1 report"`),
+			want:  [][2]string{{"$search", `"subject:\"q1 report\""`}},
+		},
+		{
+			name:  "filter and order by",
+			query: NewMailQuery().IsRead(false).OrderBy("receivedDateTime", true),
+			want: [][2]string{
+				{"$filter", "receivedDateTime ge 1900-01-01T00:00:00Z and isRead eq false"},
+				{"$orderby", "receivedDateTime desc"},
+			},
+		},
+		{
+			name:    "search with filter",
+			query:   NewMailQuery().Search("invoice").IsRead(false),
+			wantErr: true,
+		},
+		{
+			name:    "search with order by",
+			query:   NewMailQuery().Search("invoice").OrderBy("receivedDateTime", true),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.query.params()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("params() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("params() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
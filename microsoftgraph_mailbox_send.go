@@ -0,0 +1,204 @@
+package msclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	graphusers "github.com/microsoftgraph/msgraph-sdk-go/users"
+)
+
+// Message 待发送的邮件
+type Message struct {
+	Subject string
+	Body    string
+	// HTML 为 true 时 Body 按 HTML 发送，否则为纯文本
+	HTML    bool
+	To      []string
+	Cc      []string
+	Bcc     []string
+	ReplyTo []string
+	// From 以共享邮箱等其他地址发送，需要该邮箱的 Send As 权限
+	From        string
+	Attachments []Attachment
+	// SaveToSentItems 为 nil 时默认保存到已发送
+	SaveToSentItems *bool
+}
+
+// Attachment 邮件附件，Inline 为 true 时可在 HTML 中以 cid:ContentID 引用，单个附件不超过 3 MB
+type Attachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
+	Inline      bool
+	ContentID   string
+}
+
+/*
+Send 发送邮件
+https://learn.microsoft.com/en-us/graph/api/user-sendmail?view=graph-rest-1.0
+*/
+func (m *myMailBox) Send(ctx context.Context, msg Message) error {
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		return fmt.Errorf("missing mail recipients")
+	}
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	body := graphusers.NewItemSendmailSendMailPostRequestBody()
+	body.SetMessage(msg.messageable())
+	body.SetSaveToSentItems(msg.SaveToSentItems)
+	if err = m.user(client).SendMail().Post(ctx, body, nil); err != nil {
+		return fmt.Errorf("send mail failed: %v", err)
+	}
+	return nil
+}
+
+/*
+Reply 回复发件人，可附加收件人和附件；纯文本 Body 作为 comment 加在原邮件引用之上，
+HTML 或带附件时 Body 替换整个回复正文，原邮件引用不会保留
+https://learn.microsoft.com/en-us/graph/api/message-reply?view=graph-rest-1.0
+*/
+func (m *myMailBox) Reply(ctx context.Context, messageId string, msg Message) error {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	body := graphusers.NewItemMessagesItemReplyPostRequestBody()
+	comment, message := msg.reply()
+	body.SetComment(comment)
+	body.SetMessage(message)
+	if err = m.user(client).Messages().ByMessageId(messageId).Reply().Post(ctx, body, nil); err != nil {
+		return fmt.Errorf("reply mail failed: %v", err)
+	}
+	return nil
+}
+
+/*
+ReplyAll 回复全部收件人，Body 的处理与 Reply 相同
+https://learn.microsoft.com/en-us/graph/api/message-replyall?view=graph-rest-1.0
+*/
+func (m *myMailBox) ReplyAll(ctx context.Context, messageId string, msg Message) error {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	body := graphusers.NewItemMessagesItemReplyallReplyAllPostRequestBody()
+	comment, message := msg.reply()
+	body.SetComment(comment)
+	body.SetMessage(message)
+	if err = m.user(client).Messages().ByMessageId(messageId).ReplyAll().Post(ctx, body, nil); err != nil {
+		return fmt.Errorf("reply all mail failed: %v", err)
+	}
+	return nil
+}
+
+/*
+Forward 转发邮件给 msg.To，Body 的处理与 Reply 相同，纯文本 Body 作为转发说明加在原邮件之上
+https://learn.microsoft.com/en-us/graph/api/message-forward?view=graph-rest-1.0
+*/
+func (m *myMailBox) Forward(ctx context.Context, messageId string, msg Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("missing forward recipients")
+	}
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	body := graphusers.NewItemMessagesItemForwardPostRequestBody()
+	comment, message := msg.reply()
+	body.SetComment(comment)
+	body.SetMessage(message)
+	if err = m.user(client).Messages().ByMessageId(messageId).Forward().Post(ctx, body, nil); err != nil {
+		return fmt.Errorf("forward mail failed: %v", err)
+	}
+	return nil
+}
+
+// reply 回复、转发的请求内容，graph 不允许同时设置 comment 和 message.body；
+// 纯文本 Body 作为 comment 以保留原邮件引用，没有其他字段时 message 为 nil
+func (msg Message) reply() (*string, models.Messageable) {
+	var comment *string
+	if msg.Body != "" && !msg.HTML && len(msg.Attachments) == 0 {
+		text := msg.Body
+		comment = &text
+		msg.Body = ""
+	}
+	if msg.Subject == "" && msg.Body == "" && msg.From == "" && len(msg.Attachments) == 0 &&
+		len(msg.To)+len(msg.Cc)+len(msg.Bcc)+len(msg.ReplyTo) == 0 {
+		return comment, nil
+	}
+	return comment, msg.messageable()
+}
+
+// messageable 转换为 sdk 的 Message，未设置的字段不会出现在请求中
+func (msg Message) messageable() models.Messageable {
+	message := models.NewMessage()
+	if msg.Subject != "" {
+		message.SetSubject(&msg.Subject)
+	}
+	if msg.Body != "" {
+		contentType := models.TEXT_BODYTYPE
+		if msg.HTML {
+			contentType = models.HTML_BODYTYPE
+		}
+		body := models.NewItemBody()
+		body.SetContentType(&contentType)
+		body.SetContent(&msg.Body)
+		message.SetBody(body)
+	}
+	if len(msg.To) > 0 {
+		message.SetToRecipients(recipients(msg.To))
+	}
+	if len(msg.Cc) > 0 {
+		message.SetCcRecipients(recipients(msg.Cc))
+	}
+	if len(msg.Bcc) > 0 {
+		message.SetBccRecipients(recipients(msg.Bcc))
+	}
+	if len(msg.ReplyTo) > 0 {
+		message.SetReplyTo(recipients(msg.ReplyTo))
+	}
+	if msg.From != "" {
+		message.SetFrom(recipients([]string{msg.From})[0])
+	}
+	if len(msg.Attachments) > 0 {
+		attachments := make([]models.Attachmentable, 0, len(msg.Attachments))
+		for _, a := range msg.Attachments {
+			attachments = append(attachments, a.attachmentable())
+		}
+		message.SetAttachments(attachments)
+	}
+	return message
+}
+
+func (a Attachment) attachmentable() models.Attachmentable {
+	attachment := models.NewFileAttachment()
+	odataType := "#microsoft.graph.fileAttachment"
+	attachment.SetOdataType(&odataType)
+	attachment.SetName(&a.Name)
+	attachment.SetContentBytes(a.Content)
+	if a.ContentType != "" {
+		attachment.SetContentType(&a.ContentType)
+	}
+	if a.Inline {
+		attachment.SetIsInline(&a.Inline)
+		if a.ContentID != "" {
+			attachment.SetContentId(&a.ContentID)
+		}
+	}
+	return attachment
+}
+
+func recipients(addresses []string) []models.Recipientable {
+	list := make([]models.Recipientable, 0, len(addresses))
+	for _, address := range addresses {
+		address := address
+		email := models.NewEmailAddress()
+		email.SetAddress(&address)
+		recipient := models.NewRecipient()
+		recipient.SetEmailAddress(email)
+		list = append(list, recipient)
+	}
+	return list
+}
@@ -0,0 +1,58 @@
+package msclient
+
+import "testing"
+
+func TestMessageReply(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         Message
+		wantComment string
+		wantMessage bool
+		wantBody    bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:        "plain text body becomes comment",
+			msg:         Message{Body: "thanks"},
+			wantComment: "thanks",
+		},
+		{
+			name:        "comment with extra recipients",
+			msg:         Message{Body: "fyi", To: []string{"bob@contoso.com"}},
+			wantComment: "fyi",
+			wantMessage: true,
+		},
+		{
+			name:        "html body replaces reply body",
+			msg:         Message{Body: "<p>thanks</p>", HTML: true},
+			wantMessage: true,
+			wantBody:    true,
+		},
+		{
+			name:        "attachments keep body in message",
+			msg:         Message{Body: "see attached", Attachments: []Attachment{{Name: "a.txt", Content: []byte("a")}}},
+			wantMessage: true,
+			wantBody:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comment, message := tt.msg.reply()
+			var got string
+			if comment != nil {
+				got = *comment
+			}
+			if got != tt.wantComment {
+				t.Errorf("comment = %q, want %q", got, tt.wantComment)
+			}
+			if (message != nil) != tt.wantMessage {
+				t.Fatalf("message = %v, want message %v", message, tt.wantMessage)
+			}
+			if message != nil && (message.GetBody() != nil) != tt.wantBody {
+				t.Errorf("message body set = %v, want %v", message.GetBody() != nil, tt.wantBody)
+			}
+		})
+	}
+}
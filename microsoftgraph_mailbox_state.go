@@ -0,0 +1,148 @@
+package msclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	graphusers "github.com/microsoftgraph/msgraph-sdk-go/users"
+	"net/http"
+	"net/url"
+)
+
+// MarkRead 标记邮件已读或未读
+func (m *myMailBox) MarkRead(ctx context.Context, messageId string, read bool) error {
+	message := models.NewMessage()
+	message.SetIsRead(&read)
+	if err := m.patchMessage(ctx, messageId, message); err != nil {
+		return fmt.Errorf("mark mail read failed: %v", err)
+	}
+	return nil
+}
+
+// Flag 标记邮件为待办
+func (m *myMailBox) Flag(ctx context.Context, messageId string) error {
+	return m.setFlag(ctx, messageId, models.FLAGGED_FOLLOWUPFLAGSTATUS)
+}
+
+// Complete 标记邮件待办已完成
+func (m *myMailBox) Complete(ctx context.Context, messageId string) error {
+	return m.setFlag(ctx, messageId, models.COMPLETE_FOLLOWUPFLAGSTATUS)
+}
+
+// Unflag 清除邮件的待办标记
+func (m *myMailBox) Unflag(ctx context.Context, messageId string) error {
+	return m.setFlag(ctx, messageId, models.NOTFLAGGED_FOLLOWUPFLAGSTATUS)
+}
+
+func (m *myMailBox) setFlag(ctx context.Context, messageId string, status models.FollowupFlagStatus) error {
+	flag := models.NewFollowupFlag()
+	flag.SetFlagStatus(&status)
+	message := models.NewMessage()
+	message.SetFlag(flag)
+	if err := m.patchMessage(ctx, messageId, message); err != nil {
+		return fmt.Errorf("flag mail failed: %v", err)
+	}
+	return nil
+}
+
+// SetCategories 替换邮件的分类，categories 为空时清除全部分类
+func (m *myMailBox) SetCategories(ctx context.Context, messageId string, categories []string) error {
+	if categories == nil {
+		categories = []string{}
+	}
+	message := models.NewMessage()
+	message.SetCategories(categories)
+	if err := m.patchMessage(ctx, messageId, message); err != nil {
+		return fmt.Errorf("set mail categories failed: %v", err)
+	}
+	return nil
+}
+
+/*
+patchMessage 更新邮件属性
+https://learn.microsoft.com/en-us/graph/api/message-update?view=graph-rest-1.0
+*/
+func (m *myMailBox) patchMessage(ctx context.Context, messageId string, message models.Messageable) error {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	_, err = m.user(client).Messages().ByMessageId(messageId).Patch(ctx, message, nil)
+	return err
+}
+
+/*
+Move 移动邮件到 destFolder，返回邮件在新文件夹中的 id
+https://learn.microsoft.com/en-us/graph/api/message-move?view=graph-rest-1.0
+*/
+func (m *myMailBox) Move(ctx context.Context, messageId string, destFolder string) (string, error) {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return "", fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	destId, err := m.Folder(destFolder).ID(ctx)
+	if err != nil {
+		return "", err
+	}
+	body := graphusers.NewItemMessagesItemMovePostRequestBody()
+	body.SetDestinationId(&destId)
+	moved, err := m.user(client).Messages().ByMessageId(messageId).Move().Post(ctx, body, nil)
+	if err != nil {
+		return "", fmt.Errorf("move mail failed: %v", err)
+	}
+	if moved == nil || moved.GetId() == nil {
+		return "", fmt.Errorf("move mail failed: missing message id")
+	}
+	return *moved.GetId(), nil
+}
+
+/*
+Copy 复制邮件到 destFolder，返回新邮件的 id
+https://learn.microsoft.com/en-us/graph/api/message-copy?view=graph-rest-1.0
+*/
+func (m *myMailBox) Copy(ctx context.Context, messageId string, destFolder string) (string, error) {
+	client, err := microsoftGraphClient(ctx, m.token)
+	if err != nil {
+		return "", fmt.Errorf("token to ms graph client failed:%v", err)
+	}
+	destId, err := m.Folder(destFolder).ID(ctx)
+	if err != nil {
+		return "", err
+	}
+	body := graphusers.NewItemMessagesItemCopyPostRequestBody()
+	body.SetDestinationId(&destId)
+	copied, err := m.user(client).Messages().ByMessageId(messageId).Copy().Post(ctx, body, nil)
+	if err != nil {
+		return "", fmt.Errorf("copy mail failed: %v", err)
+	}
+	if copied == nil || copied.GetId() == nil {
+		return "", fmt.Errorf("copy mail failed: missing message id")
+	}
+	return *copied.GetId(), nil
+}
+
+/*
+Delete 删除邮件，permanent 为 false 时移动到已删除邮件，用户可以在 Outlook 中看到并还原；
+为 true 时永久删除，邮件进入可恢复项目，用户不可见
+https://learn.microsoft.com/en-us/graph/api/message-move?view=graph-rest-1.0
+https://learn.microsoft.com/en-us/graph/api/message-permanentdelete?view=graph-rest-1.0
+*/
+func (m *myMailBox) Delete(ctx context.Context, messageId string, permanent bool) error {
+	if !permanent {
+		if _, err := m.Move(ctx, messageId, MailFolderDeletedItems); err != nil {
+			return fmt.Errorf("delete mail failed: %v", err)
+		}
+		return nil
+	}
+
+	// sdk 暂不支持 permanentDelete，直接调用 api
+	u := fmt.Sprintf("%s/v1.0%s/messages/%s/permanentDelete", GraphAPIHost, m.userPath(), url.PathEscape(messageId))
+	body, err := graphRequest(ctx, m.token, http.MethodPost, u, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err = checkApiError(body); err != nil {
+		return fmt.Errorf("permanent delete mail failed: %v", err)
+	}
+	return nil
+}
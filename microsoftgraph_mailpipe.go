@@ -0,0 +1,534 @@
+package msclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMailPipeInterval   = time.Minute
+	DefaultMailPipeMaxRetries = 3
+)
+
+// MailHandler 处理一封邮件，返回 error 时按 MaxRetries 重试
+type MailHandler func(ctx context.Context, box MailBox, mail Mail) error
+
+// MailRule 邮件匹配规则，未设置的条件不参与匹配，全部条件满足才算匹配
+type MailRule struct {
+	// From 发件人地址，或以 @ 开头的域名，如 @contoso.com，不区分大小写
+	From string
+	// Subject 主题匹配的正则
+	Subject *regexp.Regexp
+	// AttachmentType 至少有一个附件为该类型，可以是扩展名（.pdf）或 content type（application/pdf）
+	AttachmentType string
+}
+
+// Match 判断邮件是否满足规则，AttachmentType 需要 mail.Attachments 已填充
+func (r MailRule) Match(mail Mail) bool {
+	if r.From != "" {
+		from := strings.ToLower(mail.From.Address)
+		rule := strings.ToLower(r.From)
+		if strings.HasPrefix(rule, "@") {
+			if !strings.HasSuffix(from, rule) {
+				return false
+			}
+		} else if from != rule {
+			return false
+		}
+	}
+	if r.Subject != nil && !r.Subject.MatchString(mail.Subject) {
+		return false
+	}
+	if r.AttachmentType != "" {
+		found := false
+		for _, a := range mail.Attachments {
+			if attachmentTypeMatch(a, r.AttachmentType) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func attachmentTypeMatch(a MailAttachment, attachmentType string) bool {
+	attachmentType = strings.ToLower(attachmentType)
+	ext := strings.ToLower(filepath.Ext(a.Name))
+	if strings.HasPrefix(attachmentType, ".") {
+		return ext == attachmentType
+	}
+	return strings.EqualFold(a.ContentType, attachmentType) || Ext2Mime[ext] == attachmentType
+}
+
+type MailStatus string
+
+const (
+	MailStatusSucceeded    MailStatus = "succeeded"
+	MailStatusFailed       MailStatus = "failed"        // 等待重试
+	MailStatusDeadLettered MailStatus = "dead-lettered" // 超过重试次数，不再处理
+)
+
+// MailState 一封邮件的处理状态
+type MailState struct {
+	MessageID string     `json:"messageId"`
+	Status    MailStatus `json:"status"`
+	Attempts  int        `json:"attempts"`
+	LastError string     `json:"lastError,omitempty"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// MailStateStore 保存邮件处理状态，Load 在没有记录时返回 nil
+type MailStateStore interface {
+	Load(ctx context.Context, messageId string) (*MailState, error)
+	Save(ctx context.Context, state MailState) error
+	// Failed 返回等待重试的邮件
+	Failed(ctx context.Context) ([]MailState, error)
+	// Prune 删除 UpdatedAt 早于 before 的处理成功记录，返回删除的数量
+	Prune(ctx context.Context, before time.Time) (int, error)
+}
+
+type MailPipeOptions struct {
+	// Folder 处理的文件夹，默认收件箱
+	Folder string
+	// Interval 轮询间隔，默认 DefaultMailPipeInterval
+	Interval time.Duration
+	// MaxRetries 最多处理次数，默认 DefaultMailPipeMaxRetries
+	MaxRetries int
+	// DeadLetterFolder 超过重试次数的邮件移动到该文件夹，为空时留在原文件夹
+	DeadLetterFolder string
+	// DoneFolder 处理成功的邮件移动到该文件夹，为空时留在原文件夹
+	DoneFolder string
+	// MarkRead 处理成功后标记为已读
+	MarkRead bool
+	// States 默认 NewMemoryMailStateStore
+	States MailStateStore
+	// Retention 处理成功的记录保留时长，每次 Poll 后删除更早的记录，0 表示不删除；
+	// 删除记录后邮件再次被修改（如移动、标记）时会重新处理，应大于邮件可能被修改的时间
+	Retention time.Duration
+	// Tokens 默认 NewMemoryDeltaTokenStore，没有 DeltaToken 时会处理文件夹中已有的全部邮件
+	Tokens DeltaTokenStore
+	// OnError 处理失败或轮询失败时调用，轮询失败时 messageId 为空
+	OnError func(messageId string, err error)
+}
+
+type mailRoute struct {
+	rule    MailRule
+	handler MailHandler
+}
+
+/*
+MailPipe 增量读取文件夹中的新邮件，交给第一个匹配规则的 handler 处理，并记录处理状态；
+可以定时轮询（Run），也可以在收到订阅通知时调用 Notify 立即处理
+
+	pipe := NewMailPipe(box, MailPipeOptions{DeadLetterFolder: "Inbox/Failed"})
+	pipe.Handle(MailRule{From: "@vendor.com", AttachmentType: ".pdf"}, handleInvoice)
+	handler := &NotificationHandler{OnNotification: func(ChangeNotification) { pipe.Notify() }}
+	go pipe.Run(ctx)
+*/
+type MailPipe struct {
+	box    MailBox
+	opts   MailPipeOptions
+	notify chan struct{}
+
+	mu     sync.Mutex
+	routes []mailRoute
+}
+
+func NewMailPipe(box MailBox, opts MailPipeOptions) *MailPipe {
+	if opts.Folder == "" {
+		opts.Folder = MailFolderInbox
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultMailPipeInterval
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = DefaultMailPipeMaxRetries
+	}
+	if opts.States == nil {
+		opts.States = NewMemoryMailStateStore()
+	}
+	if opts.Tokens == nil {
+		opts.Tokens = NewMemoryDeltaTokenStore()
+	}
+	return &MailPipe{box: box, opts: opts, notify: make(chan struct{}, 1)}
+}
+
+// Handle 注册规则和 handler，按注册顺序匹配
+func (p *MailPipe) Handle(rule MailRule, handler MailHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.routes = append(p.routes, mailRoute{rule: rule, handler: handler})
+}
+
+// Notify 通知 Run 立即处理一次，不会阻塞
+func (p *MailPipe) Notify() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run 按 Interval 轮询直到 ctx 结束，轮询失败会调用 OnError 并在下一次继续
+func (p *MailPipe) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.Poll(ctx); err != nil && ctx.Err() == nil {
+			p.onError("", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-p.notify:
+		}
+	}
+}
+
+/*
+Poll 处理一次：先处理新增和修改的邮件，再重试失败的邮件，返回处理成功的数量；
+本批邮件的处理状态全部保存后才保存 DeltaToken，中途退出时下一次从上一个 DeltaToken 重新读取，已成功的邮件不会重复处理
+*/
+func (p *MailPipe) Poll(ctx context.Context) (int, error) {
+	folder := p.box.Folder(p.opts.Folder)
+	token, err := p.opts.Tokens.Load(ctx, folder.DeltaKey())
+	if err != nil {
+		return 0, fmt.Errorf("load delta token failed: %v", err)
+	}
+	delta, err := folder.Delta(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	seen := map[string]bool{}
+	for _, mail := range Mails(delta.Changed).Mail() {
+		seen[mail.ID] = true
+		ok, err := p.process(ctx, mail, nil)
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			count++
+		}
+	}
+	if err = p.opts.Tokens.Save(ctx, folder.DeltaKey(), delta.DeltaToken); err != nil {
+		return count, fmt.Errorf("save delta token failed: %v", err)
+	}
+
+	failed, err := p.opts.States.Failed(ctx)
+	if err != nil {
+		return count, fmt.Errorf("load failed mails failed: %v", err)
+	}
+	for _, state := range failed {
+		if seen[state.MessageID] {
+			continue
+		}
+		var mail Mail
+		message, fetchErr := p.box.Message(ctx, state.MessageID)
+		if fetchErr == nil {
+			mail = NewMail(message)
+		} else {
+			mail = Mail{ID: state.MessageID}
+		}
+		ok, err := p.process(ctx, mail, fetchErr)
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			count++
+		}
+	}
+
+	if p.opts.Retention > 0 {
+		if _, err = p.opts.States.Prune(ctx, time.Now().Add(-p.opts.Retention)); err != nil {
+			return count, fmt.Errorf("prune mail states failed: %v", err)
+		}
+	}
+	return count, nil
+}
+
+// process 处理一封邮件，返回是否处理成功；只有读写状态失败时返回 error
+func (p *MailPipe) process(ctx context.Context, mail Mail, fetchErr error) (bool, error) {
+	state, err := p.opts.States.Load(ctx, mail.ID)
+	if err != nil {
+		return false, fmt.Errorf("load mail state failed: %v", err)
+	}
+	if state == nil {
+		state = &MailState{MessageID: mail.ID}
+	}
+	if state.Status == MailStatusSucceeded || state.Status == MailStatusDeadLettered {
+		return false, nil
+	}
+
+	handleErr := fetchErr
+	if handleErr == nil {
+		var handler MailHandler
+		handler, handleErr = p.route(ctx, &mail)
+		if handler == nil && handleErr == nil {
+			return false, nil
+		}
+		if handleErr == nil {
+			handleErr = p.call(ctx, handler, mail)
+		}
+	}
+
+	state.Attempts++
+	state.UpdatedAt = time.Now()
+	if handleErr == nil {
+		state.Status = MailStatusSucceeded
+		state.LastError = ""
+		if err = p.opts.States.Save(ctx, *state); err != nil {
+			return false, fmt.Errorf("save mail state failed: %v", err)
+		}
+		p.done(ctx, mail.ID)
+		return true, nil
+	}
+
+	p.onError(mail.ID, handleErr)
+	state.Status = MailStatusFailed
+	state.LastError = handleErr.Error()
+	if state.Attempts >= p.opts.MaxRetries {
+		state.Status = MailStatusDeadLettered
+		if p.opts.DeadLetterFolder != "" {
+			if _, err := p.box.Move(ctx, mail.ID, p.opts.DeadLetterFolder); err != nil {
+				p.onError(mail.ID, err)
+			}
+		}
+	}
+	if err = p.opts.States.Save(ctx, *state); err != nil {
+		return false, fmt.Errorf("save mail state failed: %v", err)
+	}
+	return false, nil
+}
+
+// route 返回第一个匹配的 handler，规则中有附件类型且邮件有附件时先读取附件列表并填充到 mail
+func (p *MailPipe) route(ctx context.Context, mail *Mail) (MailHandler, error) {
+	p.mu.Lock()
+	routes := append([]mailRoute{}, p.routes...)
+	p.mu.Unlock()
+
+	loaded := len(mail.Attachments) > 0
+	for _, r := range routes {
+		if r.rule.AttachmentType != "" && !loaded {
+			loaded = true
+			if mail.Raw != nil && mail.Raw.GetHasAttachments() != nil && *mail.Raw.GetHasAttachments() {
+				attachments, err := p.box.Attachments(ctx, mail.ID)
+				if err != nil {
+					return nil, err
+				}
+				mail.Attachments = attachments
+			}
+		}
+		if r.rule.Match(*mail) {
+			return r.handler, nil
+		}
+	}
+	return nil, nil
+}
+
+// call 调用 handler，panic 视为处理失败
+func (p *MailPipe) call(ctx context.Context, handler MailHandler, mail Mail) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("mail handler panic: %v", r)
+		}
+	}()
+	return handler(ctx, p.box, mail)
+}
+
+// done 处理成功后标记已读、移动到 DoneFolder，失败不影响处理状态
+func (p *MailPipe) done(ctx context.Context, messageId string) {
+	if p.opts.MarkRead {
+		if err := p.box.MarkRead(ctx, messageId, true); err != nil {
+			p.onError(messageId, err)
+		}
+	}
+	if p.opts.DoneFolder != "" {
+		if _, err := p.box.Move(ctx, messageId, p.opts.DoneFolder); err != nil {
+			p.onError(messageId, err)
+		}
+	}
+}
+
+func (p *MailPipe) onError(messageId string, err error) {
+	if p.opts.OnError != nil {
+		p.opts.OnError(messageId, err)
+	}
+}
+
+// MemoryMailStateStore 进程内保存处理状态
+type MemoryMailStateStore struct {
+	mu     sync.Mutex
+	states map[string]MailState
+}
+
+func NewMemoryMailStateStore() *MemoryMailStateStore {
+	return &MemoryMailStateStore{states: map[string]MailState{}}
+}
+
+func (s *MemoryMailStateStore) Load(_ context.Context, messageId string) (*MailState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[messageId]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (s *MemoryMailStateStore) Save(_ context.Context, state MailState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[state.MessageID] = state
+	return nil
+}
+
+func (s *MemoryMailStateStore) Failed(_ context.Context) ([]MailState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return failedMailStates(s.states), nil
+}
+
+func (s *MemoryMailStateStore) Prune(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pruneMailStates(s.states, before), nil
+}
+
+// FileMailStateStore 以 json 文件保存处理状态，首次使用时读入内存，每次修改后写回文件
+type FileMailStateStore struct {
+	mu     sync.Mutex
+	path   string
+	states map[string]MailState
+}
+
+func NewFileMailStateStore(path string) *FileMailStateStore {
+	return &FileMailStateStore{path: path}
+}
+
+func (s *FileMailStateStore) Load(_ context.Context, messageId string) (*MailState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	state, ok := s.states[messageId]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (s *FileMailStateStore) Save(_ context.Context, state MailState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	old, existed := s.states[state.MessageID]
+	s.states[state.MessageID] = state
+	if err := s.write(); err != nil {
+		// 写入失败时恢复内存中的状态，与文件保持一致
+		if existed {
+			s.states[state.MessageID] = old
+		} else {
+			delete(s.states, state.MessageID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileMailStateStore) Failed(_ context.Context) ([]MailState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return failedMailStates(s.states), nil
+}
+
+func (s *FileMailStateStore) Prune(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+	states := make(map[string]MailState, len(s.states))
+	for id, state := range s.states {
+		states[id] = state
+	}
+	n := pruneMailStates(states, before)
+	if n == 0 {
+		return 0, nil
+	}
+	old := s.states
+	s.states = states
+	if err := s.write(); err != nil {
+		s.states = old
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *FileMailStateStore) load() error {
+	if s.states != nil {
+		return nil
+	}
+	states := map[string]MailState{}
+	b, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(b) > 0 {
+		if err = json.Unmarshal(b, &states); err != nil {
+			return fmt.Errorf("decode mail state file failed: %v", err)
+		}
+	}
+	s.states = states
+	return nil
+}
+
+func (s *FileMailStateStore) write() error {
+	b, err := json.MarshalIndent(s.states, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func failedMailStates(states map[string]MailState) []MailState {
+	var failed []MailState
+	for _, state := range states {
+		if state.Status == MailStatusFailed {
+			failed = append(failed, state)
+		}
+	}
+	return failed
+}
+
+// pruneMailStates 删除 UpdatedAt 早于 before 的处理成功记录
+func pruneMailStates(states map[string]MailState, before time.Time) int {
+	var n int
+	for id, state := range states {
+		if state.Status == MailStatusSucceeded && state.UpdatedAt.Before(before) {
+			delete(states, id)
+			n++
+		}
+	}
+	return n
+}
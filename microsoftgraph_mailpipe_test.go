@@ -0,0 +1,55 @@
+package msclient
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileMailStateStorePrune(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "states.json")
+	store := NewFileMailStateStore(path)
+	states := []MailState{
+		{MessageID: "old-succeeded", Status: MailStatusSucceeded, UpdatedAt: now.Add(-48 * time.Hour)},
+		{MessageID: "new-succeeded", Status: MailStatusSucceeded, UpdatedAt: now},
+		{MessageID: "old-failed", Status: MailStatusFailed, UpdatedAt: now.Add(-48 * time.Hour)},
+		{MessageID: "old-dead", Status: MailStatusDeadLettered, UpdatedAt: now.Add(-48 * time.Hour)},
+	}
+	for _, state := range states {
+		if err := store.Save(ctx, state); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := store.Prune(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Prune() = %d, want 1", n)
+	}
+
+	// 重新打开文件，确认删除已写入
+	reopened := NewFileMailStateStore(path)
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"old-succeeded", false},
+		{"new-succeeded", true},
+		{"old-failed", true},
+		{"old-dead", true},
+	}
+	for _, tt := range tests {
+		state, err := reopened.Load(ctx, tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (state != nil) != tt.want {
+			t.Errorf("Load(%q) = %v, want exists %v", tt.id, state, tt.want)
+		}
+	}
+}
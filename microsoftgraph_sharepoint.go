@@ -1,13 +1,14 @@
 package msclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/tidwall/gjson"
 	"io"
-	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 )
 
@@ -18,20 +19,35 @@ const (
 
 const (
 	SmallFileMaxSize = 4 * 1024 * 1024
+	// bigFileChunkSize 分片大小须为 320 KiB 的整数倍
+	bigFileChunkSize = 10 * 327680
 )
 
 type SharePoint interface {
 	Upload(ctx context.Context, dirId string, file *os.File, fileName string, fileSize int64) (*Value, error)
+	UploadWithOptions(ctx context.Context, dirId string, file *os.File, fileName string, fileSize int64, opts UploadOptions) (*Value, error)
 	List(ctx context.Context, dirId string) ([]Value, error)
 	Download(ctx context.Context, fileWebUrl string) ([]byte, error)
+	Fields(ctx context.Context, itemId string) (map[string]any, error)
+	SetFields(ctx context.Context, itemId string, fields map[string]any) (map[string]any, error)
+	Search(ctx context.Context, query string, opts SearchOptions) *Pager[Value]
+	DownloadAs(ctx context.Context, itemId string, format DownloadFormat, w io.Writer) error
+	Workbook(ctx context.Context, itemId string) (Workbook, error)
 }
 
+type UploadOptions struct {
+	// Fields 上传完成后写入文档库的元数据列，如 Customer、Year
+	Fields map[string]any
+}
+
+// MySharePoint 根站点的默认文档库，其他 drive 使用 OpenDrive
 func (c *MicrosoftGraph) MySharePoint(token Token) SharePoint {
-	return &mySharePoint{token: token}
+	return &mySharePoint{token: token, drive: SiteDrive(SharePointSiteId, "")}
 }
 
 type mySharePoint struct {
 	token Token
+	drive Drive
 }
 
 func (m mySharePoint) Download(ctx context.Context, fileWebUrl string) ([]byte, error) {
@@ -39,62 +55,24 @@ func (m mySharePoint) Download(ctx context.Context, fileWebUrl string) ([]byte,
 }
 
 func (m mySharePoint) request(ctx context.Context, method string, url string, payload io.Reader, extraHeader map[string][]string) ([]byte, error) {
-	headers, err := m.token.HttpHeader(ctx)
-	if err != nil {
-		return nil, err
-	}
-	for k, vals := range extraHeader {
-		headers[k] = vals
-	}
-
-	req, err := http.NewRequest(method, url, payload)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
-	}
-	req.Header = headers
-	client, err := m.token.HttpClient(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error creating http client: %v", err)
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
-	}
-	return body, nil
+	return graphRequest(ctx, m.token, method, url, payload, extraHeader)
 }
 
-func (m mySharePoint) shareDocumentId(ctx context.Context) (string, error) {
-	url := fmt.Sprintf("%s/v1.0/sites/%s/lists", GraphAPIHost, SharePointSiteId)
-	body, err := m.request(ctx, http.MethodGet, url, nil, nil)
-	if err != nil {
-		return "", fmt.Errorf("error reading response body: %v", err)
-	}
-	var items Answer
-	_ = json.Unmarshal(body, &items)
-
-	for _, item := range items.Value {
-		if item.Name == SharePointShareDocument {
-			return item.ID, nil
-		}
-	}
-	return "", fmt.Errorf("no shared document found")
+// do 发送请求并返回原始响应，调用方负责关闭 Body
+func (m mySharePoint) do(ctx context.Context, method string, url string, payload io.Reader, extraHeader map[string][]string) (*http.Response, error) {
+	return graphDo(ctx, m.token, method, url, payload, extraHeader)
 }
 
+/*
+List 列出目录下的文件和子目录，dirId 为 root 时列出根目录；
+返回的是 driveItem，ID 为 driveItem id，可直接用于 Upload、Fields 等，不含 ContentType，判断文件夹使用 Value.IsFolder
+https://learn.microsoft.com/en-us/graph/api/driveitem-list-children?view=graph-rest-1.0
+*/
 func (m mySharePoint) List(ctx context.Context, dirId string) ([]Value, error) {
 
-	rootDirId, err := m.shareDocumentId(ctx)
-	if err != nil {
-		return nil, err
-	}
-
 	var data []Value
 
-	var url = fmt.Sprintf(`%s/v1.0/sites/%s/lists/%s/items`, GraphAPIHost, SharePointSiteId, rootDirId)
+	var url = m.drive.url("/items/%s/children", dirId)
 
 	for {
 		if url == "" {
@@ -105,11 +83,7 @@ func (m mySharePoint) List(ctx context.Context, dirId string) ([]Value, error) {
 		if err != nil {
 			return nil, err
 		}
-		for _, item := range items.Value {
-			if item.ParentReference.ID == dirId {
-				data = append(data, item)
-			}
-		}
+		data = append(data, items.Value...)
 		url = items.OdataNextLink
 	}
 
@@ -124,10 +98,68 @@ func (m mySharePoint) loopSharePointList(ctx context.Context, url string) (Answe
 		return items, fmt.Errorf("error reading response body: %v", err)
 	}
 	_ = json.Unmarshal(body, &items)
+	if items.Error.Code != "" {
+		return items, fmt.Errorf("api response error: %s", items.Error)
+	}
 	return items, nil
 }
 
 func (m mySharePoint) Upload(ctx context.Context, dirId string, file *os.File, fileName string, fileSize int64) (*Value, error) {
+	return m.UploadWithOptions(ctx, dirId, file, fileName, fileSize, UploadOptions{})
+}
+
+/*
+UploadWithOptions 上传文件后写入元数据列；写入失败时，本次新建的文件会被删除，避免留下缺少元数据的文档，
+覆盖已有文件时不删除，返回上传结果和写入的错误
+*/
+func (m mySharePoint) UploadWithOptions(ctx context.Context, dirId string, file *os.File, fileName string, fileSize int64, opts UploadOptions) (*Value, error) {
+	var existed bool
+	if len(opts.Fields) > 0 {
+		var err error
+		if existed, err = m.itemExists(ctx, dirId, fileName); err != nil {
+			return nil, err
+		}
+	}
+	v, err := m.upload(ctx, dirId, file, fileName, fileSize)
+	if err != nil || len(opts.Fields) == 0 {
+		return v, err
+	}
+	if v.ID == "" {
+		return nil, fmt.Errorf("uploaded item id not found, skip setting fields")
+	}
+	if _, err = m.SetFields(ctx, v.ID, opts.Fields); err != nil {
+		if existed {
+			return v, fmt.Errorf("set fields failed: %v", err)
+		}
+		if delErr := m.deleteItem(ctx, v.ID); delErr != nil {
+			return nil, fmt.Errorf("set fields failed: %v, rollback upload failed: %v", err, delErr)
+		}
+		return nil, fmt.Errorf("set fields failed: %v", err)
+	}
+	return v, nil
+}
+
+// itemExists 目录下是否已有同名文件
+func (m mySharePoint) itemExists(ctx context.Context, dirId string, fileName string) (bool, error) {
+	resp, err := m.do(ctx, http.MethodGet, m.drive.url("/items/%s:/%s?$select=id", dirId, url.PathEscape(fileName)), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("error reading response body: %v", err)
+	}
+	if err = checkApiError(body); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m mySharePoint) upload(ctx context.Context, dirId string, file *os.File, fileName string, fileSize int64) (*Value, error) {
 
 	ext := RegexGet(fileName, `(\.[^\.]+)$`)
 	mineType, ok := Ext2Mime[ext]
@@ -144,20 +176,16 @@ func (m mySharePoint) Upload(ctx context.Context, dirId string, file *os.File, f
 	headers.Set("Content-Length", fmt.Sprintf("%d", fileSize))
 
 	if fileSize < SmallFileMaxSize {
-		// PUT /sites/{site-id}/drive/items/{parent-id}:/{filename}:/content
-		url := fmt.Sprintf("%s/v1.0/sites/%s/drive/items/%s:/%s:/content", GraphAPIHost, SharePointSiteId, dirId, fileName)
+		// PUT {drive}/items/{parent-id}:/{filename}:/content
+		url := m.drive.url("/items/%s:/%s:/content", dirId, url.PathEscape(fileName))
 
-		return m.smallFileUpload(url, headers, file)
+		return m.smallFileUpload(ctx, url, headers, file)
 	} else {
 
-		// POST /sites/{siteId}/drive/items/{itemId}/createUploadSession
-		//sessionURL := fmt.Sprintf("%s/v1.0/sites/%s/drive/items/%s/createUploadSession", GraphAPIHost, SharePointSiteId, fileName)
-		//sessionURL := fmt.Sprintf("%s/v1.0/sites/%s/drives/${driveId}/items/${folderId}:/${fileName}:/createUploadSession", GraphAPIHost, SharePointSiteId,root fileName)
-
-		// POST /me/drive/items/{parentItemId}:/{fileName}:/createUploadSession
-		sessionURL := fmt.Sprintf("%s/v1.0/me/drive/items/%s:/%s:/createUploadSession", GraphAPIHost, dirId, fileName)
+		// POST {drive}/items/{parentItemId}:/{fileName}:/createUploadSession
+		sessionURL := m.drive.url("/items/%s:/%s:/createUploadSession", dirId, url.PathEscape(fileName))
 
-		return m.bigFileUpload(sessionURL, headers, file, fileSize)
+		return m.bigFileUpload(ctx, sessionURL, file, fileSize)
 	}
 }
 
@@ -165,12 +193,14 @@ func (m mySharePoint) Upload(ctx context.Context, dirId string, file *os.File, f
 smallFileUpload 小文件上传
 https://learn.microsoft.com/en-us/graph/api/driveitem-put-content?view=graph-rest-1.0&tabs=http#to-upload-a-new-file
 */
-func (m mySharePoint) smallFileUpload(url string, headers http.Header, file io.Reader) (*Value, error) {
-	ctx := context.Background()
+func (m mySharePoint) smallFileUpload(ctx context.Context, url string, headers http.Header, file io.Reader) (*Value, error) {
 	body, err := m.request(ctx, http.MethodPut, url, file, headers)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkApiError(body); err != nil {
+		return nil, err
+	}
 	v := &Value{}
 	_ = json.Unmarshal(body, v)
 	return v, nil
@@ -203,15 +233,7 @@ func (m mySharePoint) smallFileUpload(url string, headers http.Header, file io.R
 https://learn.microsoft.com/en-us/graph/api/driveitem-createuploadsession?view=graph-rest-1.0#upload-bytes-to-the-upload-session
 https://learn.microsoft.com/en-us/graph/sdks/large-file-upload
 */
-func (m mySharePoint) bigFileUpload(url string, headers http.Header, file io.Reader, fileSize int64) (*Value, error) {
-	ctx := context.Background()
-
-	//fileuploader.UploadSession()
-	//task := fileuploader.NewLargeFileUploadTask(m.token.HttpClient(ctx), )
-	//task.Upload(func(current int64, total int64) {
-	//
-	//
-	//})
+func (m mySharePoint) bigFileUpload(ctx context.Context, url string, file io.Reader, fileSize int64) (*Value, error) {
 
 	data, err := m.createUploadSession(ctx, url)
 	if err != nil {
@@ -224,67 +246,83 @@ func (m mySharePoint) bigFileUpload(url string, headers http.Header, file io.Rea
 	}
 
 	f := &bigFile{
+		ctx:          ctx,
+		uploadUrl:    sessionUrl.String(),
 		fileSize:     fileSize,
 		currentWrite: 0,
 		resp:         []byte{},
 	}
-
-	temp := make([]byte, 100*327680) //100 * 320kb
-	_, err = io.CopyBuffer(f, file, temp)
-
-	//req, err := http.NewRequest(http.MethodPut, sessionUrl.String(), file)
-	//if err != nil {
-	//	return nil, fmt.Errorf("error creating request: %v", err)
-	//}
-	//req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", f.currentWrite, f.currentWrite+int64(len(p))-1, f.fileSize))
-	//
-	//client, err := m.token.HttpClient(ctx)
-	//if err != nil {
-	//	return nil, fmt.Errorf("error creating http client: %v", err)
-	//}
-	//
-	//if err != nil {
-	//	return nil, fmt.Errorf("upload file failed: %v", err)
-	//}
+	if err = f.copyFrom(file); err != nil {
+		return nil, fmt.Errorf("upload file failed: %v", err)
+	}
 
 	v := &Value{}
 	_ = json.Unmarshal(f.resp, v)
 	return v, nil
 }
 
+// bigFile 分片上传到上传会话，文件和邮件附件的上传会话共用
 type bigFile struct {
+	ctx          context.Context
+	uploadUrl    string
 	fileSize     int64
 	currentWrite int64
 	resp         []byte
+	location     string
 }
 
+// copyFrom 按 bigFileChunkSize 分片读取并上传，最后一片的响应保存在 resp
+func (f *bigFile) copyFrom(r io.Reader) error {
+	temp := make([]byte, bigFileChunkSize)
+	for f.currentWrite < f.fileSize {
+		n, err := io.ReadFull(r, temp)
+		if n > 0 {
+			if _, werr := f.Write(temp[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if f.currentWrite != f.fileSize {
+		return fmt.Errorf("file size mismatch, expected %d bytes, read %d bytes", f.fileSize, f.currentWrite)
+	}
+	return nil
+}
+
+// Write 上传一个分片，uploadUrl 自带鉴权信息，不能再携带 Authorization
 func (f *bigFile) Write(p []byte) (n int, err error) {
-	//m := map[string]string{"Content-Range": fmt.Sprintf("bytes %d-%d/%d",
-	//	f.currentWrite, f.currentWrite+int64(len(p))-1, f.fileSize)}
-	//
-	//fmt.Printf("%v \n", m["Content-Range"])
-	//fmt.Printf("文件上传中==》%v \n", (f.currentWrite/f.fileSize)*100)
-	//
-	//req, err := http.NewRequest(http.MethodPut, f.sessionURL, bytes.NewReader(p))
-	//if err != nil {
-	//	return 0, err
-	//}
-	//
-	//req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", f.currentWrite, f.currentWrite+int64(len(p))-1, f.fileSize))
-	//client := &http.Client{}
-	//resp, err := client.Do(req)
-	//if err != nil {
-	//	return 0, err
-	//}
-	//body, err := ioutil.ReadAll(resp.Body)
-	//if err != nil {
-	//	return 0, err
-	//}
-	//
-	//f.resp = body
-	//f.currentWrite += int64(len(p))
-	//fmt.Printf("%v \n", gjson.GetBytes(body, "@this|@pretty"))
-	return len(p), err
+	req, err := http.NewRequestWithContext(f.ctx, http.MethodPut, f.uploadUrl, bytes.NewReader(p))
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = int64(len(p))
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", f.currentWrite, f.currentWrite+int64(len(p))-1, f.fileSize))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		if err = checkApiError(body); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("error status: %d , %s", resp.StatusCode, body)
+	}
+
+	f.resp = body
+	f.location = resp.Header.Get("Location")
+	f.currentWrite += int64(len(p))
+	return len(p), nil
 }
 
 func (m mySharePoint) createUploadSession(ctx context.Context, url string) ([]byte, error) {
@@ -321,6 +359,64 @@ func (m mySharePoint) createUploadSession(ctx context.Context, url string) ([]by
 	return body, nil
 }
 
+/*
+Fields 读取文档对应 listItem 的元数据列
+https://learn.microsoft.com/en-us/graph/api/listitem-get?view=graph-rest-1.0
+*/
+func (m mySharePoint) Fields(ctx context.Context, itemId string) (map[string]any, error) {
+	url := m.drive.url("/items/%s/listItem/fields", itemId)
+	body, err := m.request(ctx, http.MethodGet, url, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFields(body)
+}
+
+/*
+SetFields 更新文档对应 listItem 的元数据列，返回更新后的全部列
+https://learn.microsoft.com/en-us/graph/api/listitem-update?view=graph-rest-1.0
+*/
+func (m mySharePoint) SetFields(ctx context.Context, itemId string, fields map[string]any) (map[string]any, error) {
+	if itemId == "" {
+		return nil, fmt.Errorf("missing item id")
+	}
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding fields: %v", err)
+	}
+	url := m.drive.url("/items/%s/listItem/fields", itemId)
+	body, err := m.request(ctx, http.MethodPatch, url, bytes.NewReader(payload), map[string][]string{
+		"Content-Type": {"application/json"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeFields(body)
+}
+
+func decodeFields(body []byte) (map[string]any, error) {
+	if err := checkApiError(body); err != nil {
+		return nil, err
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("error decoding fields: %v", err)
+	}
+	delete(fields, "@odata.context")
+	delete(fields, "@odata.etag")
+	return fields, nil
+}
+
+func (m mySharePoint) deleteItem(ctx context.Context, itemId string) error {
+	url := m.drive.url("/items/%s", itemId)
+	body, err := m.request(ctx, http.MethodDelete, url, nil, nil)
+	if err != nil {
+		return err
+	}
+	return checkApiError(body)
+}
+
+// FileDownloadUrl 根站点默认文档库中文件的下载地址，其他 drive 使用 Drive.DownloadUrl
 func FileDownloadUrl(dirId string) string {
-	return fmt.Sprintf("%s/v1.0/sites/%s/drive/items/%s/content", GraphAPIHost, SharePointSiteId, dirId)
+	return SiteDrive(SharePointSiteId, "").DownloadUrl(dirId)
 }
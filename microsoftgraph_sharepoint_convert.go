@@ -0,0 +1,100 @@
+package msclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type DownloadFormat string
+
+const (
+	DownloadFormatPDF  DownloadFormat = "pdf"
+	DownloadFormatHTML DownloadFormat = "html"
+	DownloadFormatJPG  DownloadFormat = "jpg"
+)
+
+var ErrFormatNotConvertible = errors.New("source file can not be converted to the target format")
+
+// downloadFormatSources 各目标格式支持的源文件扩展名
+// https://learn.microsoft.com/en-us/graph/api/driveitem-get-content-format?view=graph-rest-1.0#format-options
+var downloadFormatSources = map[DownloadFormat][]string{
+	DownloadFormatPDF: {
+		"doc", "docx", "dot", "dotx", "dotm", "dsn", "dwg", "eml", "epub", "htm", "html", "markdown", "md", "msg",
+		"odp", "ods", "odt", "pps", "ppsx", "ppt", "pptx", "rtf", "tif", "tiff", "xls", "xlsm", "xlsx",
+	},
+	DownloadFormatHTML: {
+		"loop", "fluid", "wbtx",
+	},
+	DownloadFormatJPG: {
+		"3g2", "3gp", "3gp2", "3gpp", "3mf", "ai", "arw", "asf", "avi", "bas", "bash", "bat", "bmp", "c", "cbl",
+		"cmd", "cool", "cpp", "cr2", "crw", "cs", "css", "csv", "cur", "dcm", "dcm30", "dic", "dicm", "dicom",
+		"dng", "doc", "docx", "dwg", "eml", "epi", "eps", "epsf", "epsi", "epub", "erf", "fbx", "fppx", "gif",
+		"glb", "h", "hcp", "heic", "heif", "htm", "html", "ico", "icon", "java", "jfif", "jpeg", "jpg", "js",
+		"json", "key", "log", "m2ts", "m4a", "m4v", "markdown", "md", "mef", "mov", "movie", "mp3", "mp4",
+		"mp4v", "mrw", "msg", "mts", "nef", "nrw", "numbers", "obj", "odp", "odt", "ogg", "orf", "pages", "pano",
+		"pdf", "pef", "php", "pict", "pl", "ply", "png", "pot", "potm", "potx", "pps", "ppsx", "ppsxm", "ppt",
+		"pptm", "pptx", "ps", "ps1", "psb", "psd", "py", "raw", "rb", "rtf", "rw1", "rw2", "sh", "sketch", "sql",
+		"sr2", "stl", "tif", "tiff", "ts", "txt", "vb", "webm", "wma", "wmv", "xaml", "xbm", "xcf", "xd", "xml",
+		"xpm", "yaml", "yml",
+	},
+}
+
+// CanConvert 判断文件名对应的类型能否转换为目标格式
+func (f DownloadFormat) CanConvert(fileName string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(RegexGet(fileName, `\.([^\.]+)$`), "."))
+	for _, src := range downloadFormatSources[f] {
+		if src == ext {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+DownloadAs 下载文件并转换为指定格式写入 w，如 Word/PowerPoint 转 PDF
+https://learn.microsoft.com/en-us/graph/api/driveitem-get-content-format?view=graph-rest-1.0
+*/
+func (m mySharePoint) DownloadAs(ctx context.Context, itemId string, format DownloadFormat, w io.Writer) error {
+	if _, ok := downloadFormatSources[format]; !ok {
+		return fmt.Errorf("unknown download format: %s", format)
+	}
+
+	u := m.drive.url("/items/%s?$select=id,name,file", itemId)
+	body, err := m.request(ctx, http.MethodGet, u, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err = checkApiError(body); err != nil {
+		return err
+	}
+	item := &Value{}
+	if err = json.Unmarshal(body, item); err != nil {
+		return fmt.Errorf("error decoding item: %v", err)
+	}
+	if !format.CanConvert(item.Name) {
+		return fmt.Errorf("%w: %s to %s", ErrFormatNotConvertible, item.Name, format)
+	}
+
+	u = m.drive.url("/items/%s/content?format=%s", itemId, format)
+	resp, err := m.do(ctx, http.MethodGet, u, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		b, _ := io.ReadAll(resp.Body)
+		if err = checkApiError(b); err != nil {
+			return err
+		}
+		return fmt.Errorf("error status: %d , %s", resp.StatusCode, b)
+	}
+	if _, err = io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("error writing converted file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package msclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	DefaultSearchPageSize = 50
+)
+
+type SearchOptions struct {
+	// PageSize 每页条数，默认 DefaultSearchPageSize
+	PageSize int32
+	// Max 最多返回条数，<= 0 不限制
+	Max int
+	// CrossSite 使用 Microsoft Search /search/query 跨站点搜索，默认只搜索当前文档库
+	CrossSite bool
+}
+
+/*
+Search 在文档库中搜索文件，结果补全 ParentReference.Path
+https://learn.microsoft.com/en-us/graph/api/driveitem-search?view=graph-rest-1.0
+https://learn.microsoft.com/en-us/graph/api/search-query?view=graph-rest-1.0
+*/
+func (m mySharePoint) Search(ctx context.Context, query string, opts SearchOptions) *Pager[Value] {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultSearchPageSize
+	}
+	paths := map[string]string{}
+	if opts.CrossSite {
+		var from int32
+		return newPager(opts.Max, func(ctx context.Context) ([]Value, bool, error) {
+			items, more, err := m.searchQuery(ctx, query, from, opts.PageSize)
+			if err != nil {
+				return nil, false, err
+			}
+			from += int32(len(items))
+			return m.fillParentPath(ctx, items, paths), more, nil
+		})
+	}
+
+	q := strings.ReplaceAll(query, `'`, `''`)
+	next := m.drive.url("/root/search(q='%s')?$top=%d", url.PathEscape(q), opts.PageSize)
+	return newPager(opts.Max, func(ctx context.Context) ([]Value, bool, error) {
+		body, err := m.request(ctx, http.MethodGet, next, nil, nil)
+		if err != nil {
+			return nil, false, err
+		}
+		if err = checkApiError(body); err != nil {
+			return nil, false, err
+		}
+		var ans Answer
+		if err = json.Unmarshal(body, &ans); err != nil {
+			return nil, false, fmt.Errorf("error decoding search result: %v", err)
+		}
+		next = ans.OdataNextLink
+		return m.fillParentPath(ctx, ans.Value, paths), next != "", nil
+	})
+}
+
+func (m mySharePoint) searchQuery(ctx context.Context, query string, from int32, size int32) ([]Value, bool, error) {
+	payload, err := json.Marshal(map[string]any{
+		"requests": []map[string]any{{
+			"entityTypes": []string{"driveItem"},
+			"query":       map[string]string{"queryString": query},
+			"from":        from,
+			"size":        size,
+		}},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	u := fmt.Sprintf("%s/v1.0/search/query", GraphAPIHost)
+	body, err := m.request(ctx, http.MethodPost, u, bytes.NewReader(payload), map[string][]string{
+		"Content-Type": {"application/json"},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if err = checkApiError(body); err != nil {
+		return nil, false, err
+	}
+
+	var items []Value
+	containers := gjson.GetBytes(body, "value.0.hitsContainers")
+	more := false
+	for _, container := range containers.Array() {
+		for _, hit := range container.Get("hits").Array() {
+			v := Value{}
+			if err = json.Unmarshal([]byte(hit.Get("resource").Raw), &v); err != nil {
+				return nil, false, fmt.Errorf("error decoding search hit: %v", err)
+			}
+			items = append(items, v)
+		}
+		more = more || container.Get("moreResultsAvailable").Bool()
+	}
+	return items, more, nil
+}
+
+// fillParentPath 搜索结果通常不带 parentReference.path，按父目录 id 查询并缓存
+func (m mySharePoint) fillParentPath(ctx context.Context, items []Value, paths map[string]string) []Value {
+	for i := range items {
+		ref := &items[i].ParentReference
+		if ref.Path != "" || ref.ID == "" || ref.DriveID == "" {
+			continue
+		}
+		path, ok := paths[ref.ID]
+		if !ok {
+			path = m.itemPath(ctx, ref.DriveID, ref.ID)
+			paths[ref.ID] = path
+		}
+		ref.Path = path
+	}
+	return items
+}
+
+// itemPath 返回 item 自身的路径，格式与 parentReference.path 一致，如 /drives/{id}/root:/dir
+func (m mySharePoint) itemPath(ctx context.Context, driveId string, itemId string) string {
+	u := fmt.Sprintf("%s/v1.0/drives/%s/items/%s?$select=name,root,parentReference", GraphAPIHost, driveId, itemId)
+	body, err := m.request(ctx, http.MethodGet, u, nil, nil)
+	if err != nil || checkApiError(body) != nil {
+		return ""
+	}
+	if gjson.GetBytes(body, "root").Exists() {
+		return fmt.Sprintf("/drives/%s/root:", driveId)
+	}
+	parent := gjson.GetBytes(body, "parentReference.path").String()
+	if parent == "" {
+		return ""
+	}
+	return parent + "/" + gjson.GetBytes(body, "name").String()
+}
@@ -0,0 +1,75 @@
+package msclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+/*
+Sites 按关键字搜索站点，search 为空时返回全部站点
+https://learn.microsoft.com/en-us/graph/api/site-search?view=graph-rest-1.0
+*/
+func (c *MicrosoftGraph) Sites(ctx context.Context, token Token, search string) ([]Site, error) {
+	if search == "" {
+		search = "*"
+	}
+	u := fmt.Sprintf("%s/v1.0/sites?search=%s", GraphAPIHost, url.QueryEscape(search))
+
+	var sites []Site
+	for u != "" {
+		var page struct {
+			OdataNextLink string `json:"@odata.nextLink"`
+			Value         []Site `json:"value"`
+		}
+		if err := getJson(ctx, token, u, &page); err != nil {
+			return nil, fmt.Errorf("list sites failed: %v", err)
+		}
+		sites = append(sites, page.Value...)
+		u = page.OdataNextLink
+	}
+	return sites, nil
+}
+
+/*
+Drives 列出站点下的文档库及其配额
+https://learn.microsoft.com/en-us/graph/api/drive-list?view=graph-rest-1.0
+*/
+func (c *MicrosoftGraph) Drives(ctx context.Context, token Token, siteId string) ([]DriveInfo, error) {
+	if siteId == "" {
+		siteId = SharePointSiteId
+	}
+	u := fmt.Sprintf("%s/v1.0/sites/%s/drives?$select=id,name,description,driveType,webUrl,createdDateTime,lastModifiedDateTime,owner,quota",
+		GraphAPIHost, siteId)
+
+	var drives []DriveInfo
+	for u != "" {
+		var page struct {
+			OdataNextLink string      `json:"@odata.nextLink"`
+			Value         []DriveInfo `json:"value"`
+		}
+		if err := getJson(ctx, token, u, &page); err != nil {
+			return nil, fmt.Errorf("list drives failed: %v", err)
+		}
+		drives = append(drives, page.Value...)
+		u = page.OdataNextLink
+	}
+	return drives, nil
+}
+
+// getJson GET 请求并解析 json 响应
+func getJson(ctx context.Context, token Token, url string, v any) error {
+	body, err := graphRequest(ctx, token, http.MethodGet, url, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err = checkApiError(body); err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("error decoding response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package msclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ChangeType string
+
+const (
+	ChangeTypeCreated ChangeType = "created"
+	ChangeTypeUpdated ChangeType = "updated"
+	ChangeTypeDeleted ChangeType = "deleted"
+)
+
+// 各类资源订阅的最长有效期
+// https://learn.microsoft.com/en-us/graph/api/resources/subscription?view=graph-rest-1.0#subscription-lifetime
+const (
+	DriveSubscriptionMaxLifetime = 42300 * time.Minute
+	MailSubscriptionMaxLifetime  = 10080 * time.Minute
+	ListSubscriptionMaxLifetime  = 42300 * time.Minute
+)
+
+type Subscription struct {
+	ID                       string    `json:"id,omitempty"`
+	Resource                 string    `json:"resource"`
+	ChangeType               string    `json:"changeType"` // 多个类型用逗号分隔，如 created,updated
+	NotificationURL          string    `json:"notificationUrl"`
+	LifecycleNotificationURL string    `json:"lifecycleNotificationUrl,omitempty"`
+	ClientState              string    `json:"clientState,omitempty"`
+	ExpirationDateTime       time.Time `json:"expirationDateTime"`
+}
+
+// DriveResource drive 根目录的订阅资源，drive 下任意文件变化都会通知
+func DriveResource(drive Drive) string {
+	return drive.path + "/root"
+}
+
+// MailResource 邮件订阅资源，userId 为空时使用当前用户，folder 为空时订阅全部邮件
+func MailResource(userId string, folder string) string {
+	user := "/me"
+	if userId != "" {
+		user = "/users/" + userId
+	}
+	if folder == "" {
+		return user + "/messages"
+	}
+	return fmt.Sprintf("%s/mailFolders('%s')/messages", user, folder)
+}
+
+// ListResource SharePoint 列表订阅资源
+func ListResource(siteId string, listId string) string {
+	return fmt.Sprintf("/sites/%s/lists/%s", siteId, listId)
+}
+
+// subscriptionMaxLifetime 按资源类型返回订阅最长有效期
+func subscriptionMaxLifetime(resource string) time.Duration {
+	if strings.Contains(resource, "/messages") || strings.Contains(resource, "/mailFolders") {
+		return MailSubscriptionMaxLifetime
+	}
+	if strings.Contains(resource, "/lists/") {
+		return ListSubscriptionMaxLifetime
+	}
+	return DriveSubscriptionMaxLifetime
+}
+
+type Subscriptions interface {
+	Create(ctx context.Context, sub Subscription) (*Subscription, error)
+	Renew(ctx context.Context, id string, expiration time.Time) (*Subscription, error)
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context) ([]Subscription, error)
+	AutoRenew(ctx context.Context, before time.Duration, onError func(sub Subscription, err error))
+}
+
+func (c *MicrosoftGraph) Subscriptions(token Token) Subscriptions {
+	return &subscriptions{token: token}
+}
+
+type subscriptions struct {
+	token Token
+}
+
+/*
+Create 创建订阅，未设置 ExpirationDateTime 时使用资源允许的最长有效期
+https://learn.microsoft.com/en-us/graph/api/subscription-post-subscriptions?view=graph-rest-1.0
+*/
+func (s *subscriptions) Create(ctx context.Context, sub Subscription) (*Subscription, error) {
+	if sub.ExpirationDateTime.IsZero() {
+		sub.ExpirationDateTime = time.Now().Add(subscriptionMaxLifetime(sub.Resource) - time.Minute)
+	}
+	u := fmt.Sprintf("%s/v1.0/subscriptions", GraphAPIHost)
+	return s.send(ctx, http.MethodPost, u, sub)
+}
+
+/*
+Renew 延长订阅有效期
+https://learn.microsoft.com/en-us/graph/api/subscription-update?view=graph-rest-1.0
+*/
+func (s *subscriptions) Renew(ctx context.Context, id string, expiration time.Time) (*Subscription, error) {
+	u := fmt.Sprintf("%s/v1.0/subscriptions/%s", GraphAPIHost, id)
+	return s.send(ctx, http.MethodPatch, u, map[string]time.Time{"expirationDateTime": expiration})
+}
+
+func (s *subscriptions) Delete(ctx context.Context, id string) error {
+	u := fmt.Sprintf("%s/v1.0/subscriptions/%s", GraphAPIHost, id)
+	body, err := graphRequest(ctx, s.token, http.MethodDelete, u, nil, nil)
+	if err != nil {
+		return fmt.Errorf("delete subscription failed: %v", err)
+	}
+	return checkApiError(body)
+}
+
+func (s *subscriptions) List(ctx context.Context) ([]Subscription, error) {
+	u := fmt.Sprintf("%s/v1.0/subscriptions", GraphAPIHost)
+
+	var subs []Subscription
+	for u != "" {
+		var page struct {
+			OdataNextLink string         `json:"@odata.nextLink"`
+			Value         []Subscription `json:"value"`
+		}
+		if err := getJson(ctx, s.token, u, &page); err != nil {
+			return nil, fmt.Errorf("list subscriptions failed: %v", err)
+		}
+		subs = append(subs, page.Value...)
+		u = page.OdataNextLink
+	}
+	return subs, nil
+}
+
+/*
+AutoRenew 启动后台 goroutine，定期检查订阅，在到期前 before 时间内续期到最长有效期，ctx 结束时退出
+*/
+func (s *subscriptions) AutoRenew(ctx context.Context, before time.Duration, onError func(sub Subscription, err error)) {
+	if onError == nil {
+		onError = func(Subscription, error) {}
+	}
+	if before <= 0 {
+		before = time.Hour
+	}
+	go func() {
+		ticker := time.NewTicker(before / 2)
+		defer ticker.Stop()
+		for {
+			s.renewExpiring(ctx, before, onError)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *subscriptions) renewExpiring(ctx context.Context, before time.Duration, onError func(sub Subscription, err error)) {
+	subs, err := s.List(ctx)
+	if err != nil {
+		onError(Subscription{}, err)
+		return
+	}
+	for _, sub := range subs {
+		if time.Until(sub.ExpirationDateTime) > before {
+			continue
+		}
+		expiration := time.Now().Add(subscriptionMaxLifetime(sub.Resource) - time.Minute)
+		if _, err = s.Renew(ctx, sub.ID, expiration); err != nil {
+			onError(sub, err)
+		}
+	}
+}
+
+func (s *subscriptions) send(ctx context.Context, method string, url string, payload any) (*Subscription, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding subscription: %v", err)
+	}
+	body, err := graphRequest(ctx, s.token, method, url, bytes.NewReader(b), map[string][]string{
+		"Content-Type": {"application/json"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = checkApiError(body); err != nil {
+		return nil, err
+	}
+	sub := &Subscription{}
+	if err = json.Unmarshal(body, sub); err != nil {
+		return nil, fmt.Errorf("error decoding subscription: %v", err)
+	}
+	return sub, nil
+}
+
+// ChangeNotification 订阅推送的变更通知，LifecycleEvent 不为空时为生命周期通知
+// https://learn.microsoft.com/en-us/graph/api/resources/changenotification?view=graph-rest-1.0
+type ChangeNotification struct {
+	SubscriptionID                 string     `json:"subscriptionId"`
+	SubscriptionExpirationDateTime time.Time  `json:"subscriptionExpirationDateTime"`
+	ChangeType                     ChangeType `json:"changeType"`
+	Resource                       string     `json:"resource"`
+	ClientState                    string     `json:"clientState"`
+	TenantID                       string     `json:"tenantId"`
+	LifecycleEvent                 string     `json:"lifecycleEvent,omitempty"` // reauthorizationRequired, subscriptionRemoved, missed
+	ResourceData                   struct {
+		ODataType string `json:"@odata.type"`
+		ODataID   string `json:"@odata.id"`
+		ID        string `json:"id"`
+	} `json:"resourceData"`
+}
+
+// NotificationHandler 接收订阅推送的 http.Handler，完成 validationToken 握手并校验 clientState，
+// 通过校验的通知发送到 Events 或交给 OnNotification 处理；
+// Events 应使用带缓冲的 channel，发送不会阻塞，缓冲已满时返回 503 由 graph 稍后重新推送整批通知，
+// 此时已放入 Events 的通知会再次收到，消费方需要按通知内容去重
+type NotificationHandler struct {
+	ClientState    string
+	Events         chan<- ChangeNotification
+	OnNotification func(n ChangeNotification)
+}
+
+func (h *NotificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 创建订阅时 graph 会带上 validationToken，需要在 10 秒内原样返回
+	if token := r.URL.Query().Get("validationToken"); token != "" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(token))
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var payload struct {
+		Value []ChangeNotification `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, fmt.Sprintf("decode notification failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	notifications := make([]ChangeNotification, 0, len(payload.Value))
+	for _, n := range payload.Value {
+		// clientState 不符的通知不是本应用的订阅发出的，直接丢弃
+		if h.ClientState != "" && n.ClientState != h.ClientState {
+			continue
+		}
+		notifications = append(notifications, n)
+	}
+
+	// 返回 202 之前放入 Events，放不下时让 graph 重试，避免确认后丢失
+	if h.Events != nil {
+		for _, n := range notifications {
+			select {
+			case h.Events <- n:
+			default:
+				w.Header().Set("Retry-After", "10")
+				http.Error(w, "notification queue is full", http.StatusServiceUnavailable)
+				return
+			}
+		}
+	}
+
+	// graph 要求 3 秒内响应，先返回 202 再调用 OnNotification
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusAccepted)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	if h.OnNotification != nil {
+		for _, n := range notifications {
+			h.OnNotification(n)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package msclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotificationHandler(t *testing.T) {
+	const body = `{"value":[
+		{"subscriptionId":"s1","changeType":"created","clientState":"secret","resource":"me/messages/1"},
+		{"subscriptionId":"s1","changeType":"created","clientState":"other","resource":"me/messages/2"},
+		{"subscriptionId":"s1","changeType":"updated","clientState":"secret","resource":"me/messages/3"}
+	]}`
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		buffer     int
+		wantStatus int
+		wantBody   string
+		wantEvents []string
+	}{
+		{
+			name:       "validation token echo",
+			method:     http.MethodPost,
+			target:     "/notify?validationToken=abc%20123",
+			wantStatus: http.StatusOK,
+			wantBody:   "abc 123",
+		},
+		{
+			name:       "deliver matching client state",
+			method:     http.MethodPost,
+			target:     "/notify",
+			body:       body,
+			buffer:     10,
+			wantStatus: http.StatusAccepted,
+			wantEvents: []string{"me/messages/1", "me/messages/3"},
+		},
+		{
+			name:       "client state mismatch dropped",
+			method:     http.MethodPost,
+			target:     "/notify",
+			body:       `{"value":[{"subscriptionId":"s1","clientState":"other","resource":"me/messages/2"}]}`,
+			buffer:     10,
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "full queue asks graph to retry",
+			method:     http.MethodPost,
+			target:     "/notify",
+			body:       body,
+			buffer:     1,
+			wantStatus: http.StatusServiceUnavailable,
+			wantEvents: []string{"me/messages/1"},
+		},
+		{
+			name:       "invalid body",
+			method:     http.MethodPost,
+			target:     "/notify",
+			body:       "{",
+			buffer:     10,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "method not allowed",
+			method:     http.MethodGet,
+			target:     "/notify",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan ChangeNotification, tt.buffer)
+			var called []string
+			handler := &NotificationHandler{
+				ClientState:    "secret",
+				Events:         events,
+				OnNotification: func(n ChangeNotification) { called = append(called, n.Resource) },
+			}
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			resp := rec.Result()
+			b, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantBody != "" && string(b) != tt.wantBody {
+				t.Errorf("body = %q, want %q", b, tt.wantBody)
+			}
+			close(events)
+			var got []string
+			for n := range events {
+				got = append(got, n.Resource)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantEvents, ",") {
+				t.Errorf("events = %q, want %q", got, tt.wantEvents)
+			}
+			if tt.wantStatus == http.StatusAccepted && strings.Join(called, ",") != strings.Join(tt.wantEvents, ",") {
+				t.Errorf("OnNotification = %q, want %q", called, tt.wantEvents)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package msclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type Worksheet struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Position   int    `json:"position"`
+	Visibility string `json:"visibility"`
+}
+
+type NamedItem struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"` // Range, String, Integer, Double, Boolean, Error
+	Value   any    `json:"value"`
+	Visible bool   `json:"visible"`
+	Comment string `json:"comment"`
+}
+
+type Workbook interface {
+	CreateSession(ctx context.Context, persist bool) error
+	CloseSession(ctx context.Context) error
+	Worksheets(ctx context.Context) ([]Worksheet, error)
+	Range(ctx context.Context, sheet string, address string) ([][]any, error)
+	SetRange(ctx context.Context, sheet string, address string, values [][]any) error
+	AppendRows(ctx context.Context, table string, rows [][]any) error
+	NamedItems(ctx context.Context) ([]NamedItem, error)
+	NamedItemRange(ctx context.Context, name string) ([][]any, error)
+}
+
+/*
+Workbook 打开文档库中的 Excel 文件，只支持 .xlsx
+https://learn.microsoft.com/en-us/graph/api/resources/excel?view=graph-rest-1.0
+*/
+func (m mySharePoint) Workbook(ctx context.Context, itemId string) (Workbook, error) {
+	body, err := m.request(ctx, http.MethodGet, m.drive.url("/items/%s?$select=id,name", itemId), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkApiError(body); err != nil {
+		return nil, err
+	}
+	item := &Value{}
+	if err = json.Unmarshal(body, item); err != nil {
+		return nil, fmt.Errorf("error decoding item: %v", err)
+	}
+	if !strings.HasSuffix(strings.ToLower(item.Name), ".xlsx") {
+		return nil, fmt.Errorf("%s is not an excel workbook", item.Name)
+	}
+	return &workbook{token: m.token, base: m.drive.url("/items/%s/workbook", itemId)}, nil
+}
+
+type workbook struct {
+	token     Token
+	base      string
+	sessionId string
+}
+
+/*
+CreateSession 创建会话，之后的请求都带上 workbook-session-id，persist 为 true 时修改会保存到文件
+https://learn.microsoft.com/en-us/graph/api/workbook-createsession?view=graph-rest-1.0
+*/
+func (w *workbook) CreateSession(ctx context.Context, persist bool) error {
+	var session struct {
+		ID string `json:"id"`
+	}
+	if err := w.send(ctx, http.MethodPost, "/createSession", map[string]bool{"persistChanges": persist}, &session); err != nil {
+		return fmt.Errorf("create workbook session failed: %v", err)
+	}
+	w.sessionId = session.ID
+	return nil
+}
+
+func (w *workbook) CloseSession(ctx context.Context) error {
+	if w.sessionId == "" {
+		return nil
+	}
+	if err := w.send(ctx, http.MethodPost, "/closeSession", nil, nil); err != nil {
+		return fmt.Errorf("close workbook session failed: %v", err)
+	}
+	w.sessionId = ""
+	return nil
+}
+
+func (w *workbook) Worksheets(ctx context.Context) ([]Worksheet, error) {
+	var ans struct {
+		Value []Worksheet `json:"value"`
+	}
+	if err := w.send(ctx, http.MethodGet, "/worksheets", nil, &ans); err != nil {
+		return nil, err
+	}
+	return ans.Value, nil
+}
+
+/*
+Range 读取工作表区域的值，如 address 为 A1:C10
+https://learn.microsoft.com/en-us/graph/api/worksheet-range?view=graph-rest-1.0
+*/
+func (w *workbook) Range(ctx context.Context, sheet string, address string) ([][]any, error) {
+	var ans struct {
+		Values [][]any `json:"values"`
+	}
+	if err := w.send(ctx, http.MethodGet, rangePath(sheet, address), nil, &ans); err != nil {
+		return nil, err
+	}
+	return ans.Values, nil
+}
+
+/*
+SetRange 写入工作表区域，values 的行列数需要与 address 一致
+https://learn.microsoft.com/en-us/graph/api/range-update?view=graph-rest-1.0
+*/
+func (w *workbook) SetRange(ctx context.Context, sheet string, address string, values [][]any) error {
+	return w.send(ctx, http.MethodPatch, rangePath(sheet, address), map[string][][]any{"values": values}, nil)
+}
+
+/*
+AppendRows 在表格末尾追加行
+https://learn.microsoft.com/en-us/graph/api/table-post-rows?view=graph-rest-1.0
+*/
+func (w *workbook) AppendRows(ctx context.Context, table string, rows [][]any) error {
+	return w.send(ctx, http.MethodPost, fmt.Sprintf("/tables/%s/rows", url.PathEscape(table)), map[string][][]any{"values": rows}, nil)
+}
+
+func (w *workbook) NamedItems(ctx context.Context) ([]NamedItem, error) {
+	var ans struct {
+		Value []NamedItem `json:"value"`
+	}
+	if err := w.send(ctx, http.MethodGet, "/names", nil, &ans); err != nil {
+		return nil, err
+	}
+	return ans.Value, nil
+}
+
+// NamedItemRange 读取命名区域的值
+func (w *workbook) NamedItemRange(ctx context.Context, name string) ([][]any, error) {
+	var ans struct {
+		Values [][]any `json:"values"`
+	}
+	if err := w.send(ctx, http.MethodGet, fmt.Sprintf("/names/%s/range", url.PathEscape(name)), nil, &ans); err != nil {
+		return nil, err
+	}
+	return ans.Values, nil
+}
+
+// rangePath 工作表名称和地址中的单引号需要转义
+func rangePath(sheet string, address string) string {
+	sheet = url.PathEscape(strings.ReplaceAll(sheet, `'`, `''`))
+	address = url.PathEscape(strings.ReplaceAll(address, `'`, `''`))
+	return fmt.Sprintf("/worksheets('%s')/range(address='%s')", sheet, address)
+}
+
+func (w *workbook) send(ctx context.Context, method string, path string, payload any, v any) error {
+	headers := map[string][]string{}
+	if w.sessionId != "" {
+		headers["workbook-session-id"] = []string{w.sessionId}
+	}
+	var reader io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %v", err)
+		}
+		reader = bytes.NewReader(b)
+		headers["Content-Type"] = []string{"application/json"}
+	}
+
+	body, err := graphRequest(ctx, w.token, method, w.base+path, reader, headers)
+	if err != nil {
+		return err
+	}
+	if err = checkApiError(body); err != nil {
+		return err
+	}
+	if v == nil || len(body) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("error decoding response: %v", err)
+	}
+	return nil
+}
@@ -20,7 +20,7 @@ type ErrJson struct {
 func (e ErrJson) String() string {
 	b, err := json.Marshal(e)
 	if err != nil {
-		return fmt.Sprintf("code: %s, message: %d, Gender: %s", e.Code, e.Message)
+		return fmt.Sprintf("code: %s, message: %s", e.Code, e.Message)
 	} else {
 		return fmt.Sprintf("%s", b)
 	}
@@ -58,6 +58,7 @@ type Value struct {
 		DriveType string `json:"driveType"`
 		ID        string `json:"id"`
 		Path      string `json:"path"`
+		SiteID    string `json:"siteId,omitempty"`
 	} `json:"parentReference"`
 	FileSystemInfo struct {
 		CreatedDateTime      time.Time `json:"createdDateTime"`
@@ -98,6 +99,12 @@ func (v *Value) UnmarshalJSON(b []byte) error {
 	*v = Value(*xf)
 	return nil
 }
+
+// IsFolder 是否为文件夹，根据 driveItem 的 folder 属性判断，listItem 根据 contentType 判断
+func (v *Value) IsFolder() bool {
+	return v.Folder.ChildCount >= 0 || v.ContentType.Name == "Folder"
+}
+
 func (v *Value) NameFromUrl() string {
 	tmp := strings.Split(v.WebURL, `/`)
 	if len(tmp) == 0 {
@@ -118,6 +125,9 @@ type ContentType struct {
 	Name string `json:"name"`
 }
 
+// IsFolder 按 listItem 的 contentType 判断是否为文件夹
+//
+// Deprecated: List 返回的 driveItem 不含 contentType，使用 Value.IsFolder
 func (t ContentType) IsFolder() bool {
 	return t.Name == "Folder"
 }
@@ -130,6 +140,18 @@ type Answer struct {
 	Error          ErrJson `json:"error,omitempty"`
 }
 
+// checkApiError 解析 graph api 返回的错误信息
+func checkApiError(body []byte) error {
+	var tpl struct {
+		Error ErrJson `json:"error,omitempty"`
+	}
+	_ = json.Unmarshal(body, &tpl)
+	if tpl.Error.Code != "" {
+		return fmt.Errorf("api response error: %s", tpl.Error)
+	}
+	return nil
+}
+
 // CheckAnswerValid 判断收到的 Answer 是否正常
 func CheckAnswerValid(ans Answer, relativePath string) error {
 	if ans.Error.Code != "" {
@@ -137,3 +159,60 @@ func CheckAnswerValid(ans Answer, relativePath string) error {
 	}
 	return nil
 }
+
+type Quota struct {
+	Total     int64  `json:"total"`
+	Used      int64  `json:"used"`
+	Remaining int64  `json:"remaining"`
+	Deleted   int64  `json:"deleted"`
+	State     string `json:"state"` // normal, nearing, critical, exceeded
+}
+
+// UsedPercent 已用空间百分比，Total 为 0 时返回 0
+func (q Quota) UsedPercent() float64 {
+	if q.Total <= 0 {
+		return 0
+	}
+	return float64(q.Used) * 100 / float64(q.Total)
+}
+
+// NearlyFull state 为 nearing/critical/exceeded 时表示空间即将用尽
+func (q Quota) NearlyFull() bool {
+	return q.State != "" && q.State != "normal"
+}
+
+type Site struct {
+	ID                   string    `json:"id"`
+	Name                 string    `json:"name"`
+	DisplayName          string    `json:"displayName"`
+	Description          string    `json:"description"`
+	WebURL               string    `json:"webUrl"`
+	CreatedDateTime      time.Time `json:"createdDateTime"`
+	LastModifiedDateTime time.Time `json:"lastModifiedDateTime"`
+	SiteCollection       struct {
+		Hostname string `json:"hostname"`
+	} `json:"siteCollection,omitempty"`
+}
+
+// DriveInfo 文档库（drive）信息
+type DriveInfo struct {
+	ID                   string    `json:"id"`
+	Name                 string    `json:"name"`
+	Description          string    `json:"description"`
+	DriveType            string    `json:"driveType"` // personal, business, documentLibrary
+	WebURL               string    `json:"webUrl"`
+	CreatedDateTime      time.Time `json:"createdDateTime"`
+	LastModifiedDateTime time.Time `json:"lastModifiedDateTime"`
+	Owner                struct {
+		User struct {
+			Email       string `json:"email"`
+			ID          string `json:"id"`
+			DisplayName string `json:"displayName"`
+		} `json:"user"`
+		Group struct {
+			ID          string `json:"id"`
+			DisplayName string `json:"displayName"`
+		} `json:"group"`
+	} `json:"owner,omitempty"`
+	Quota Quota `json:"quota"`
+}
@@ -0,0 +1,55 @@
+package msclient
+
+import "context"
+
+// Pager 分页迭代器，每次 Next 拉取一页，直到没有下一页或达到 max 条
+//
+//	for pager.HasNext() {
+//		page, err := pager.Next(ctx)
+//	}
+type Pager[T any] struct {
+	fetch func(ctx context.Context) ([]T, bool, error)
+	max   int
+	count int
+	done  bool
+}
+
+// newPager fetch 返回当前页数据以及是否还有下一页，max <= 0 时不限制条数
+func newPager[T any](max int, fetch func(ctx context.Context) ([]T, bool, error)) *Pager[T] {
+	return &Pager[T]{fetch: fetch, max: max}
+}
+
+func (p *Pager[T]) HasNext() bool {
+	return !p.done
+}
+
+func (p *Pager[T]) Next(ctx context.Context) ([]T, error) {
+	if p.done {
+		return nil, nil
+	}
+	items, more, err := p.fetch(ctx)
+	if err != nil {
+		p.done = true
+		return nil, err
+	}
+	if p.max > 0 && p.count+len(items) >= p.max {
+		items = items[:p.max-p.count]
+		more = false
+	}
+	p.count += len(items)
+	p.done = !more
+	return items, nil
+}
+
+// All 拉取剩余的全部数据
+func (p *Pager[T]) All(ctx context.Context) ([]T, error) {
+	var all []T
+	for p.HasNext() {
+		items, err := p.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+	}
+	return all, nil
+}
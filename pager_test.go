@@ -0,0 +1,70 @@
+package msclient
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// pagesOf 按 pages 依次返回每一页，最后一页之后没有下一页
+func pagesOf(pages ...[]int) func(ctx context.Context) ([]int, bool, error) {
+	i := 0
+	return func(ctx context.Context) ([]int, bool, error) {
+		page := pages[i]
+		i++
+		return page, i < len(pages), nil
+	}
+}
+
+func TestPagerAll(t *testing.T) {
+	tests := []struct {
+		name  string
+		max   int
+		pages [][]int
+		want  []int
+		calls int
+	}{
+		{name: "unlimited", max: 0, pages: [][]int{{1, 2}, {3, 4}, {5}}, want: []int{1, 2, 3, 4, 5}, calls: 3},
+		{name: "max inside page", max: 3, pages: [][]int{{1, 2}, {3, 4}, {5}}, want: []int{1, 2, 3}, calls: 2},
+		{name: "max at page end", max: 2, pages: [][]int{{1, 2}, {3, 4}}, want: []int{1, 2}, calls: 1},
+		{name: "max larger than total", max: 10, pages: [][]int{{1}, {2}}, want: []int{1, 2}, calls: 2},
+		{name: "empty", max: 0, pages: [][]int{{}}, want: nil, calls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			fetch := pagesOf(tt.pages...)
+			pager := newPager(tt.max, func(ctx context.Context) ([]int, bool, error) {
+				calls++
+				return fetch(ctx)
+			})
+			got, err := pager.All(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("All() = %v, want %v", got, tt.want)
+			}
+			if calls != tt.calls {
+				t.Errorf("fetch called %d times, want %d", calls, tt.calls)
+			}
+			if pager.HasNext() {
+				t.Error("HasNext() = true after All")
+			}
+		})
+	}
+}
+
+func TestPagerError(t *testing.T) {
+	wantErr := errors.New("boom")
+	pager := newPager(0, func(ctx context.Context) ([]int, bool, error) {
+		return nil, true, wantErr
+	})
+	if _, err := pager.Next(context.Background()); !errors.Is(err, wantErr) {
+		t.Fatalf("Next() error = %v, want %v", err, wantErr)
+	}
+	if pager.HasNext() {
+		t.Error("HasNext() = true after error")
+	}
+}
@@ -0,0 +1,76 @@
+package msclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/confidential"
+	"golang.org/x/oauth2"
+	"net/http"
+	"sync"
+)
+
+/*
+AppToken 以应用身份（client credentials）获取 token，不需要用户登录，使用应用权限访问 UserMailBox、SharedMailBox、UserDrive、UserCalendar 等；
+过期后自动重新获取
+https://learn.microsoft.com/en-us/graph/auth-v2-service
+*/
+func (c *MicrosoftGraph) AppToken(ctx context.Context) (Token, error) {
+	t := &appToken{app: c.app, scopes: []string{DefaultMicrosoftGraphScope}}
+	if _, err := t.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+type appToken struct {
+	app    confidential.Client
+	scopes []string
+
+	mu    sync.Mutex
+	oauth *oauth2.Token
+}
+
+func (t *appToken) refresh(ctx context.Context) (*oauth2.Token, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.oauth.Valid() {
+		return t.oauth, nil
+	}
+	result, err := t.app.AcquireTokenByCredential(ctx, t.scopes)
+	if err != nil {
+		return nil, fmt.Errorf("acquire app token failed: %v", err)
+	}
+	t.oauth = &oauth2.Token{
+		AccessToken: result.AccessToken,
+		TokenType:   "Bearer",
+		Expiry:      result.ExpiresOn,
+	}
+	return t.oauth, nil
+}
+
+func (t *appToken) HttpHeader(ctx context.Context) (http.Header, error) {
+	oauth, err := t.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return http.Header{
+		"Authorization": []string{"Bearer " + oauth.AccessToken},
+	}, nil
+}
+
+func (t *appToken) HttpClient(ctx context.Context) (*http.Client, error) {
+	if _, err := t.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(ctx, appTokenSource{ctx: ctx, token: t}), nil
+}
+
+// appTokenSource 供 oauth2.NewClient 使用，每次请求前检查并刷新 token
+type appTokenSource struct {
+	ctx   context.Context
+	token *appToken
+}
+
+func (s appTokenSource) Token() (*oauth2.Token, error) {
+	return s.token.refresh(s.ctx)
+}